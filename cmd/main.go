@@ -0,0 +1,427 @@
+// The gophkeeper server: private data storage with end-to-end encryption.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	pb "github.com/dtroode/gophkeeper-api"
+
+	"github.com/dtroode/gophkeeper-server/internal/config"
+	appContext "github.com/dtroode/gophkeeper-server/internal/context"
+	"github.com/dtroode/gophkeeper-server/internal/handler"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/metrics"
+	"github.com/dtroode/gophkeeper-server/internal/middleware"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+	"github.com/dtroode/gophkeeper-server/internal/repository/postgres"
+	"github.com/dtroode/gophkeeper-server/internal/server"
+	"github.com/dtroode/gophkeeper-server/internal/service"
+	"github.com/dtroode/gophkeeper-server/internal/storage/minio"
+	"github.com/dtroode/gophkeeper-server/internal/timing"
+)
+
+func main() {
+	cfg, err := config.New()
+	if err != nil {
+		os.Stderr.WriteString("failed to load config: " + err.Error() + "\n")
+		os.Exit(1)
+	}
+	l := logger.New(cfg.LogLevel, cfg.LogFormat)
+	if err := cfg.Validate(); err != nil {
+		l.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	db, err := postgres.NewConection(ctx, cfg.DatabaseDSN, cfg.DBConnectAttempts, cfg.DBConnectBackoff, l)
+	if err != nil {
+		l.Error("failed to connect to postgres", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if cfg.ReadReplicaDSN != "" {
+		if err := db.ConnectReplica(ctx, cfg.ReadReplicaDSN, cfg.DBConnectAttempts, cfg.DBConnectBackoff, l); err != nil {
+			l.Error("failed to connect to read replica", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.RunMigrations {
+		if err := postgres.RunMigrations(ctx, db, l); err != nil {
+			l.Error("failed to run migrations", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// The idempotent-create conflict clause silently degrades to ordinary
+	// inserts when its partial unique index is missing, so verify the schema
+	// up front instead of discovering duplicates under client retries.
+	indexOK, err := postgres.HasRequestIDConflictIndex(ctx, db)
+	if err != nil {
+		l.Error("failed to verify request_id conflict index", "error", err)
+		os.Exit(1)
+	}
+	if !indexOK {
+		if cfg.StrictSchemaCheck {
+			l.Error("partial unique index on records (owner_id, request_id) is missing; idempotent creates would produce duplicates")
+			os.Exit(1)
+		}
+		l.Warn("partial unique index on records (owner_id, request_id) is missing; idempotent creates will produce duplicates under retries")
+	}
+
+	objectStorage, err := minio.New(ctx, cfg.MinioEndpoint, cfg.MinioAccessKey, cfg.MinioSecretKey, cfg.Buckets(), cfg.MinioUseSSL, cfg.StorageOpTimeout, l)
+	if err != nil {
+		l.Error("failed to connect to minio", "error", err)
+		os.Exit(1)
+	}
+	if cfg.MinioLifecycleDays > 0 {
+		if err := objectStorage.ApplyLifecycle(ctx, cfg.MinioLifecyclePrefix, cfg.MinioLifecycleDays); err != nil {
+			l.Error("failed to apply bucket lifecycle", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	checks := []startupCheck{
+		{"postgres", db.Ping},
+		{"minio", objectStorage.CheckBuckets},
+	}
+	if err := runStartupChecks(ctx, checks, cfg.StartupCheckAttempts, cfg.StartupCheckBackoff, cfg.StartupCheckTimeout, l); err != nil {
+		l.Error("startup dependency checks failed", "error", err)
+		os.Exit(1)
+	}
+	// Pending migrations are a one-shot diagnostic, not a retried probe: the
+	// condition is not transient. They fail startup only when this process
+	// owns the schema (RUN_MIGRATIONS=true), where anything still pending
+	// after the runner means the rollout is broken. A deployment managing
+	// schema out of band is warned and left to start; its queries fail
+	// loudly anyway if the schema really is behind.
+	pending, err := postgres.PendingMigrations(ctx, db)
+	switch {
+	case err != nil && cfg.RunMigrations:
+		l.Error("migration check failed", "error", err)
+		os.Exit(1)
+	case err != nil:
+		l.Warn("migration check failed", "error", err)
+	case len(pending) > 0 && cfg.RunMigrations:
+		l.Error("migrations pending after startup runner", "count", len(pending), "first", pending[0])
+		os.Exit(1)
+	case len(pending) > 0:
+		l.Warn("schema migrations pending, schema managed out of band", "count", len(pending), "first", pending[0])
+	}
+
+	userRepo := postgres.NewUserRepository(db)
+	recordRepo := postgres.NewRecordRepository(db)
+	signupRepo := postgres.NewPendingSignupRepository(db)
+	loginRepo := postgres.NewPendingLoginRepository(db)
+	tokenRepo := postgres.NewRefreshTokenRepository(db)
+	eventRepo := postgres.NewEventRepository(db)
+	ackRepo := postgres.NewTombstoneAckRepository(db)
+
+	var recordStore model.RecordStore = recordRepo
+	var objectStore model.Storage = objectStorage
+	if cfg.LogOpLatency {
+		recordStore = timing.NewRecordStore(recordRepo, l)
+		objectStore = timing.NewStorage(objectStorage, l)
+	}
+
+	metricsCollector := metrics.New()
+	if cfg.MetricsAddress != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metricsCollector.Handler())
+			if err := http.ListenAndServe(cfg.MetricsAddress, mux); err != nil {
+				l.Error("metrics endpoint failed", "error", err)
+			}
+		}()
+	}
+
+	tokenService := service.NewTokenService(tokenRepo, []byte(cfg.JWTSecret), cfg.AccessTokenTTL, cfg.RefreshTokenTTL, cfg.MaxRefreshTokensPerUser, cfg.TokenLeeway, l)
+	authService := service.NewAuth(userRepo, signupRepo, loginRepo, tokenService,
+		model.NewKDFParams(cfg.KDFTime, cfg.KDFMem, cfg.KDFPar),
+		model.NewKDFParams(cfg.KDFMinTime, cfg.KDFMinMem, cfg.KDFMinPar),
+		cfg.PendingSessionTTL, cfg.LoginSessionReuseWindow, cfg.MaxPendingSessions, cfg.RegistrationPrivacy, l)
+	recordService := service.NewRecord(recordStore, userRepo, eventRepo, ackRepo, objectStore, service.RecordLimits{
+		MaxNameLength:          cfg.MaxRecordNameLength,
+		MaxDescriptionLength:   cfg.MaxRecordDescriptionLength,
+		MaxChunkSize:           cfg.MaxStreamChunkSize,
+		DefaultChunkSize:       cfg.DefaultChunkSize,
+		MinChunkSize:           cfg.MinChunkSize,
+		MaxUploadChunkSize:     cfg.MaxUploadChunkSize,
+		MaxInlineBinarySize:    cfg.MaxInlineBinarySize,
+		MaxInlineStreamPayload: cfg.MaxInlineStreamPayload,
+		MaxUnaryResponseSize:   cfg.MaxUnaryResponseSize,
+		MaxPayloadSize: map[model.RecordType]int{
+			model.RecordTypeLogin: cfg.MaxLoginPayloadSize,
+			model.RecordTypeNote:  cfg.MaxNotePayloadSize,
+			model.RecordTypeCard:  cfg.MaxCardPayloadSize,
+		},
+		AllowedAlgs:          cfg.AllowedAlgs,
+		MaxEncryptedKeySize:  cfg.MaxEncryptedKeySize,
+		UploadIdleTimeout:    cfg.UploadIdleTimeout,
+		MaxTagsPerRecord:     cfg.MaxTagsPerRecord,
+		MaxTagLength:         cfg.MaxTagLength,
+		MaxRecordsPerUser:    cfg.MaxRecordsPerUser,
+		JobConcurrency:       cfg.JobConcurrency,
+		SanitizeDescriptions: cfg.SanitizeDescriptions,
+		MaxSyncLookback:      cfg.MaxSyncLookback,
+		TombstoneAckWindow:   cfg.TombstoneAckWindow,
+		PresignedUploads:     cfg.PresignedUploads,
+		PresignedPutExpiry:   cfg.PresignedPutExpiry,
+		VerifyUploads:        cfg.VerifyUploads,
+	}, cfg.ObjectKeyPrefix, cfg.ExposeOwnershipErrors, metricsCollector, l)
+
+	purgeLock := postgres.NewJobLock(db, "record-purge")
+	go runPurgeLoop(ctx, recordService, purgeLock, cfg.PurgeInterval, cfg.TombstoneRetention, cfg.MaxSyncLookback, l)
+
+	if cfg.ReencryptObjects {
+		checkpointRepo := postgres.NewCheckpointRepository(db)
+		maintenanceService := service.NewMaintenance(objectStore, checkpointRepo, cfg.JobConcurrency, l)
+		reencryptLock := postgres.NewJobLock(db, "reencrypt-objects")
+		go runReencryptJob(ctx, maintenanceService, reencryptLock, l)
+	}
+
+	if cfg.KDFRepairOnStart {
+		kdfRepairLock := postgres.NewJobLock(db, "kdf-repair")
+		go runKDFRepairJob(ctx, authService, kdfRepairLock, l)
+	}
+
+	contextManager := appContext.New()
+	authHandler := handler.NewAuth(authService, contextManager, l)
+	recordHandler := handler.NewRecord(recordService, contextManager, l)
+
+	draining := middleware.NewDraining()
+	healthServer := health.NewServer()
+	grpcServer := registerGRPCServer(cfg, l, tokenService, contextManager, authHandler, recordHandler, draining, healthServer, metricsCollector)
+
+	listenOpts := server.ListenOptions{Backlog: cfg.ListenBacklog, ReusePort: cfg.ListenReusePort}
+	var security server.SecurityLayer
+	switch {
+	case cfg.EnableTLS && cfg.GRPCCertPEM != "":
+		security = server.NewTLSListenerFromPEM([]byte(cfg.GRPCCertPEM), []byte(cfg.GRPCKeyPEM), listenOpts)
+	case cfg.EnableTLS:
+		security = server.NewTLSListener(cfg.GRPCCertFileName, cfg.GRPCKeyFileName, listenOpts)
+	default:
+		security = server.NewPlainListener(listenOpts)
+	}
+	srv := server.NewGRPCServer(grpcServer, security, cfg.GRPCAddress, l)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(cfg.GRPCNetwork)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case sig := <-stop:
+		l.Info("shutting down", "signal", sig.String())
+		// Flip readiness first so load balancers stop routing, then wait
+		// for in-flight requests to drain before stopping the server.
+		healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		if !draining.Drain(cfg.ShutdownTimeout) {
+			l.Warn("shutdown deadline reached with requests in flight", "active", draining.Active())
+		}
+		srv.Stop()
+	case err := <-errCh:
+		if err != nil {
+			l.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// runPurgeLoop periodically hard-deletes records whose soft-delete retention
+// expired, removing their stored objects first, and prunes outbox events
+// older than the sync lookback window. An advisory lock makes the job a
+// singleton across replicas: each cycle attempts the lock and skips the run
+// when another replica already holds it.
+func runPurgeLoop(ctx context.Context, records *service.Record, lock *postgres.JobLock, interval, retention, eventLookback time.Duration, l *logger.Logger) {
+	if interval <= 0 {
+		return
+	}
+	defer lock.Release(context.Background())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, err := lock.TryAcquire(ctx)
+		if err != nil {
+			l.Error("purge lock attempt failed", "error", err)
+			continue
+		}
+		if !held {
+			continue
+		}
+		purged, err := records.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			l.Error("purge run failed", "error", err)
+			continue
+		}
+		if purged > 0 {
+			l.Info("purged deleted records", "count", purged)
+		}
+		// An unbounded lookback means arbitrarily old cursors are still
+		// served, so events are only pruned when the cap is set.
+		if eventLookback <= 0 {
+			continue
+		}
+		pruned, err := records.PruneEvents(ctx, time.Now().Add(-eventLookback))
+		if err != nil {
+			l.Error("event prune failed", "error", err)
+			continue
+		}
+		if pruned > 0 {
+			l.Info("pruned record events", "count", pruned)
+		}
+	}
+}
+
+// runReencryptJob runs the one-shot object re-encryption pass. The advisory
+// lock keeps it a singleton across replicas; the job itself checkpoints its
+// progress, so a replica that dies mid-run is resumed by the next start with
+// the flag still set.
+func runReencryptJob(ctx context.Context, maintenance *service.Maintenance, lock *postgres.JobLock, l *logger.Logger) {
+	defer lock.Release(context.Background())
+	held, err := lock.TryAcquire(ctx)
+	if err != nil {
+		l.Error("reencrypt lock attempt failed", "error", err)
+		return
+	}
+	if !held {
+		l.Info("reencrypt job already running on another replica")
+		return
+	}
+	copied, err := maintenance.ReEncryptObjects(ctx)
+	if err != nil {
+		l.Error("reencrypt run failed", "copied", copied, "error", err)
+		return
+	}
+	l.Info("reencrypt run complete", "copied", copied)
+}
+
+// startupCheck is one named dependency probe run before the server starts
+// serving.
+type startupCheck struct {
+	name  string
+	probe func(ctx context.Context) error
+}
+
+// runStartupChecks probes every dependency in order, logging each outcome, so
+// container logs name the unhealthy dependency instead of showing one terse
+// fatal. Failures are retried with a fixed backoff — MinIO may still be
+// booting, another replica may be mid-migration — and every probe attempt
+// runs under its own timeout.
+func runStartupChecks(ctx context.Context, checks []startupCheck, attempts int, backoff, timeout time.Duration, l *logger.Logger) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	for _, check := range checks {
+		var err error
+		for attempt := 1; attempt <= attempts; attempt++ {
+			probeCtx, cancel := ctx, func() {}
+			if timeout > 0 {
+				probeCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			err = check.probe(probeCtx)
+			cancel()
+			if err == nil {
+				break
+			}
+			l.Warn("startup check failed", "check", check.name, "attempt", attempt, "of", attempts, "error", err)
+			if attempt < attempts {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(backoff):
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("startup check %s: %w", check.name, err)
+		}
+		l.Info("startup check passed", "check", check.name)
+	}
+	return nil
+}
+
+// runKDFRepairJob runs the one-shot KDF blob repair scan. The advisory lock
+// keeps it a singleton across replicas; the scan is idempotent, so a replica
+// that dies mid-run is finished by the next start with the flag still set.
+func runKDFRepairJob(ctx context.Context, auth *service.Auth, lock *postgres.JobLock, l *logger.Logger) {
+	defer lock.Release(context.Background())
+	held, err := lock.TryAcquire(ctx)
+	if err != nil {
+		l.Error("kdf repair lock attempt failed", "error", err)
+		return
+	}
+	if !held {
+		l.Info("kdf repair already running on another replica")
+		return
+	}
+	repaired, corrupt, err := auth.RepairKDFBlobs(ctx)
+	if err != nil {
+		l.Error("kdf repair run failed", "repaired", repaired, "corrupt", corrupt, "error", err)
+		return
+	}
+	l.Info("kdf repair run complete", "repaired", repaired, "corrupt", corrupt)
+}
+
+// registerGRPCServer builds the gRPC server with the interceptor chain and
+// registers all services.
+func registerGRPCServer(
+	cfg *config.Config,
+	l *logger.Logger,
+	tokenService *service.TokenService,
+	contextManager *appContext.Manager,
+	authHandler *handler.Auth,
+	recordHandler *handler.Record,
+	draining *middleware.Draining,
+	healthServer *health.Server,
+	metricsCollector *metrics.Metrics,
+) *grpc.Server {
+	logging := middleware.NewLogging(l, cfg.LogSampleRate, cfg.LogSlowThreshold)
+	authenticate := middleware.NewAuthenticate(tokenService, contextManager, middleware.DefaultSkipPrefixes, cfg.AuthMetadataKey, cfg.AuthScheme, cfg.StreamTokenGrace, l)
+	streamLimit := middleware.NewStreamLimit(contextManager, cfg.MaxStreamsPerUser, l)
+	sanitize := middleware.NewSanitizeMetadata()
+	validate := middleware.NewValidate()
+	counters := middleware.NewMetrics(metricsCollector)
+	compression := middleware.NewCompression(cfg.CompressResponses)
+
+	// sanitize runs before authenticate so a client-supplied user_id header
+	// is gone before anything can trust it; streamLimit sits after
+	// authenticate because it counts by user ID from the authenticated
+	// stream context. validate runs after authenticate so field-level errors
+	// are only reported to callers that hold a valid token. counters sits
+	// outside authenticate and streamLimit so their rejections are counted
+	// too.
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(draining.Unary, logging.HandleGRPC, counters.Unary, sanitize.Unary, authenticate.Unary, validate.Unary, compression.Unary),
+		grpc.ChainStreamInterceptor(draining.Stream, logging.HandleGRPCStream, counters.Stream, sanitize.Stream, authenticate.Stream, streamLimit.Stream, validate.Stream, compression.Stream),
+	)
+	pb.RegisterAuthServer(s, authHandler)
+	pb.RegisterRecordsServer(s, recordHandler)
+	healthpb.RegisterHealthServer(s, healthServer)
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	if cfg.EnableReflection {
+		reflection.Register(s)
+	}
+	return s
+}
@@ -0,0 +1,18 @@
+module github.com/dtroode/gophkeeper-server
+
+go 1.24
+
+require (
+	github.com/caarlos0/env/v11 v11.2.2
+	github.com/dtroode/gophkeeper-api v0.0.0
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/uuid v1.6.0
+	github.com/jackc/pgx/v5 v5.7.2
+	github.com/minio/minio-go/v7 v7.0.80
+	golang.org/x/sync v0.10.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241216192217-9240e9c98484
+	google.golang.org/grpc v1.69.2
+	google.golang.org/protobuf v1.36.1
+)
+
+replace github.com/dtroode/gophkeeper-api => ./pkg/api
@@ -0,0 +1,253 @@
+// Package apierrors defines the typed errors the service layer returns and the
+// gRPC codes handlers map them to.
+package apierrors
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Domain identifies this service in google.rpc.ErrorInfo details attached to
+// gRPC statuses.
+const Domain = "gophkeeper-server"
+
+// Reason values are the stable machine-readable error identifiers carried in
+// ErrorInfo details. Clients branch on these instead of matching message
+// substrings, so they must never be renamed.
+const (
+	ReasonEmailTaken         = "EMAIL_TAKEN"
+	ReasonInvalidEmail       = "INVALID_EMAIL"
+	ReasonUserNotFound       = "USER_NOT_FOUND"
+	ReasonRecordNotFound     = "RECORD_NOT_FOUND"
+	ReasonForbidden          = "RECORD_NOT_OWNED"
+	ReasonSessionNotFound    = "SESSION_NOT_FOUND"
+	ReasonSessionExpired     = "SESSION_EXPIRED"
+	ReasonInvalidCredentials = "INVALID_CREDENTIALS"
+	ReasonTokenExpired       = "TOKEN_EXPIRED"
+	ReasonTokenRevoked       = "TOKEN_REVOKED"
+	ReasonInvalidScope       = "INVALID_SCOPE"
+	ReasonInvalidRecordType  = "INVALID_RECORD_TYPE"
+	ReasonBatchTooLarge      = "BATCH_TOO_LARGE"
+	ReasonKDFMismatch        = "KDF_MISMATCH"
+	ReasonCorruptKDF         = "CORRUPT_KDF_PARAMS"
+	ReasonVersionConflict    = "VERSION_CONFLICT"
+	ReasonBinaryTooLarge     = "BINARY_TOO_LARGE_FOR_INLINE"
+	ReasonPayloadTooLarge    = "PAYLOAD_TOO_LARGE_FOR_UNARY"
+	ReasonInvalidMetadata    = "INVALID_METADATA"
+	ReasonStreamIdleTimeout  = "STREAM_IDLE_TIMEOUT"
+	ReasonFullResyncRequired = "FULL_RESYNC_REQUIRED"
+	ReasonPresignedDisabled  = "PRESIGNED_UPLOADS_DISABLED"
+	ReasonNotObjectBacked    = "NOT_OBJECT_BACKED"
+	ReasonQuotaExceeded      = "QUOTA_EXCEEDED"
+	ReasonUploadFailed       = "UPLOAD_FAILED"
+	ReasonUploadNotRecorded  = "UPLOAD_NOT_RECORDED"
+	ReasonUploadOrphaned     = "UPLOAD_ORPHANED"
+	ReasonInvalidFieldLength = "INVALID_FIELD_LENGTH"
+	ReasonTooManyPending     = "TOO_MANY_PENDING_SESSIONS"
+	ReasonTypeChangeDenied   = "TYPE_CHANGE_NOT_ALLOWED"
+)
+
+// APIError is a service-level error carrying the gRPC code it should be
+// reported with. Template and Args keep the unformatted message parts so
+// handlers can log or render them; Message is the precomputed rendered form.
+// Reason is the stable identifier handlers attach as an ErrorInfo detail.
+type APIError struct {
+	GRPCCode codes.Code
+	Reason   string
+	Template string
+	Args     []any
+	Message  string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func newError(code codes.Code, reason, template string, args ...any) *APIError {
+	return &APIError{
+		GRPCCode: code,
+		Reason:   reason,
+		Template: template,
+		Args:     args,
+		Message:  fmt.Sprintf(template, args...),
+	}
+}
+
+// NewErrEmailIsTaken reports that an account with the given email exists.
+func NewErrEmailIsTaken(email string) *APIError {
+	return newError(codes.AlreadyExists, ReasonEmailTaken, "email %s is taken", email)
+}
+
+// NewErrInvalidEmail reports a syntactically invalid email address.
+func NewErrInvalidEmail(email string) *APIError {
+	return newError(codes.InvalidArgument, ReasonInvalidEmail, "invalid email: %s", email)
+}
+
+// NewErrUserNotFound reports a missing user account.
+func NewErrUserNotFound() *APIError {
+	return newError(codes.NotFound, ReasonUserNotFound, "user not found")
+}
+
+// NewErrRecordNotFound reports a missing record. It is also returned for
+// records the caller does not own, so record existence is not leaked.
+func NewErrRecordNotFound() *APIError {
+	return newError(codes.NotFound, ReasonRecordNotFound, "record not found")
+}
+
+// NewErrForbidden reports an ownership mismatch explicitly. Only used when the
+// operator opts out of the NotFound masking for trusted deployments.
+func NewErrForbidden() *APIError {
+	return newError(codes.PermissionDenied, ReasonForbidden, "record belongs to another user")
+}
+
+// NewErrSessionNotFound reports a missing or already consumed auth session.
+func NewErrSessionNotFound() *APIError {
+	return newError(codes.NotFound, ReasonSessionNotFound, "session not found")
+}
+
+// NewErrSessionExpired reports an expired auth session.
+func NewErrSessionExpired() *APIError {
+	return newError(codes.DeadlineExceeded, ReasonSessionExpired, "session expired")
+}
+
+// NewErrInvalidCredentials reports a failed proof verification.
+func NewErrInvalidCredentials() *APIError {
+	return newError(codes.Unauthenticated, ReasonInvalidCredentials, "invalid credentials")
+}
+
+// NewErrInvalidScope reports a requested token scope that is unknown or not
+// grantable through the API.
+func NewErrInvalidScope(scope string) *APIError {
+	return newError(codes.InvalidArgument, ReasonInvalidScope, "scope %s is unknown or not grantable", scope)
+}
+
+// NewErrTokenExpired reports an expired access or refresh token.
+func NewErrTokenExpired() *APIError {
+	return newError(codes.Unauthenticated, ReasonTokenExpired, "token expired")
+}
+
+// NewErrTokenRevoked reports a revoked refresh token.
+func NewErrTokenRevoked() *APIError {
+	return newError(codes.Unauthenticated, ReasonTokenRevoked, "token revoked")
+}
+
+// NewErrInvalidRecordType reports an unknown or inappropriate record type.
+func NewErrInvalidRecordType(t string) *APIError {
+	return newError(codes.InvalidArgument, ReasonInvalidRecordType, "invalid record type: %s", t)
+}
+
+// NewErrBatchTooLarge reports a batch request exceeding the per-call cap.
+func NewErrBatchTooLarge(max int) *APIError {
+	return newError(codes.InvalidArgument, ReasonBatchTooLarge, "batch exceeds maximum of %d ids", max)
+}
+
+// NewErrKDFMismatch reports that a registration was completed with KDF
+// parameters differing from the server-issued ones.
+func NewErrKDFMismatch() *APIError {
+	return newError(codes.InvalidArgument, ReasonKDFMismatch, "kdf params do not match the issued ones")
+}
+
+// NewErrCorruptKDF reports that a stored KDF parameter blob no longer parses.
+// This is server-side data damage, not a client mistake; the distinct reason
+// lets operators tell the affected rows apart from other internal failures.
+func NewErrCorruptKDF() *APIError {
+	return newError(codes.Internal, ReasonCorruptKDF, "stored kdf params are corrupt")
+}
+
+// NewErrVersionConflict reports that a mutation carried a stale expected
+// version, so the client must re-fetch and merge before retrying.
+func NewErrVersionConflict(expected, actual int64) *APIError {
+	return newError(codes.Aborted, ReasonVersionConflict, "version conflict: expected %d, stored %d", expected, actual)
+}
+
+// NewErrBinaryTooLargeForInline reports a binary record whose payload exceeds
+// the inline threshold and must be fetched via the streaming endpoint.
+func NewErrBinaryTooLargeForInline(max int64) *APIError {
+	return newError(codes.FailedPrecondition, ReasonBinaryTooLarge, "binary payload exceeds %d bytes, use GetRecordStream", max)
+}
+
+// NewErrPayloadTooLargeForUnary reports an inline payload that has grown past
+// the unary response size limit and can only be fetched via the streaming
+// endpoint.
+func NewErrPayloadTooLargeForUnary(size, max int64) *APIError {
+	return newError(codes.FailedPrecondition, ReasonPayloadTooLarge, "encrypted payload of %d bytes exceeds the %d-byte response limit, use GetRecordStream", size, max)
+}
+
+// NewErrStreamIdleTimeout reports a streaming upload aborted because no chunk
+// arrived within the idle window.
+func NewErrStreamIdleTimeout(window time.Duration) *APIError {
+	return newError(codes.DeadlineExceeded, ReasonStreamIdleTimeout, "no chunk received within %s", window)
+}
+
+// NewErrPresignedDisabled reports that this deployment does not issue
+// presigned upload URLs.
+func NewErrPresignedDisabled() *APIError {
+	return newError(codes.FailedPrecondition, ReasonPresignedDisabled, "presigned uploads are disabled")
+}
+
+// NewErrNotObjectBacked reports an object-storage operation on a record whose
+// payload is stored inline.
+func NewErrNotObjectBacked() *APIError {
+	return newError(codes.FailedPrecondition, ReasonNotObjectBacked, "record payload is not object-backed")
+}
+
+// NewErrFullResyncRequired tells a client whose delta cursor falls outside the
+// server's lookback window to drop the cursor and sync from scratch.
+func NewErrFullResyncRequired(window time.Duration) *APIError {
+	return newError(codes.FailedPrecondition, ReasonFullResyncRequired, "updated_after is older than the %s lookback window, perform a full resync", window)
+}
+
+// NewErrQuotaExceeded reports that creating the record would exceed the
+// per-user record count limit.
+func NewErrQuotaExceeded(max int) *APIError {
+	return newError(codes.ResourceExhausted, ReasonQuotaExceeded, "record limit of %d reached", max)
+}
+
+// NewErrUploadFailed reports that the payload upload failed before any record
+// row was written. Nothing is stored; the client can retry the create.
+func NewErrUploadFailed() *APIError {
+	return newError(codes.Unavailable, ReasonUploadFailed, "payload upload failed, nothing was stored")
+}
+
+// NewErrUploadNotRecorded reports that the payload was uploaded but the record
+// row could not be written; the uploaded object was removed again. Nothing is
+// stored, so the client can safely retry.
+func NewErrUploadNotRecorded() *APIError {
+	return newError(codes.Aborted, ReasonUploadNotRecorded, "record was not created; the uploaded payload was removed, safe to retry")
+}
+
+// NewErrUploadOrphaned reports that the record row could not be written and
+// the uploaded object could not be removed either, so an orphaned object may
+// remain in storage. The record does not exist; a retry uploads under a fresh
+// key.
+func NewErrUploadOrphaned() *APIError {
+	return newError(codes.Internal, ReasonUploadOrphaned, "record was not created and the uploaded payload may remain stored")
+}
+
+// NewErrInvalidMetadata reports invalid record metadata.
+func NewErrInvalidMetadata(reason string) *APIError {
+	return newError(codes.InvalidArgument, ReasonInvalidMetadata, "invalid metadata: %s", reason)
+}
+
+// NewErrInvalidFieldLength reports a client-supplied binary field outside its
+// accepted byte-length range.
+func NewErrInvalidFieldLength(field string, size, min, max int) *APIError {
+	return newError(codes.InvalidArgument, ReasonInvalidFieldLength, "%s must be %d to %d bytes, got %d", field, min, max, size)
+}
+
+// NewErrTypeChangeDenied reports a forbidden record type transition: binary
+// records have object-backed payloads while the other types hold inline data,
+// so conversions to or from binary are rejected.
+func NewErrTypeChangeDenied(from, to string) *APIError {
+	return newError(codes.FailedPrecondition, ReasonTypeChangeDenied, "cannot change record type from %s to %s", from, to)
+}
+
+// NewErrTooManyPendingSessions reports that the login already has the maximum
+// number of unconsumed auth sessions open; earlier ones must be completed or
+// expire before a new exchange can start.
+func NewErrTooManyPendingSessions(max int) *APIError {
+	return newError(codes.ResourceExhausted, ReasonTooManyPending, "too many pending auth sessions, limit is %d", max)
+}
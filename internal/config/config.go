@@ -0,0 +1,312 @@
+// Package config loads server configuration from the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+// Config is the full server configuration.
+type Config struct {
+	GRPCAddress string `env:"GRPC_ADDRESS" envDefault:":3200"`
+	// GRPCNetwork is the listener network: "tcp", or "unix" with GRPCAddress
+	// naming the socket file, for sidecar deployments.
+	GRPCNetwork string `env:"GRPC_NETWORK" envDefault:"tcp"`
+	// ListenBacklog overrides the TCP accept-queue length passed to
+	// listen(2); the kernel still caps it at net.core.somaxconn. Zero keeps
+	// the Go default, which asks for the kernel maximum. ListenReusePort sets
+	// SO_REUSEPORT so several server processes can share the address, each
+	// with its own accept loop. Both apply to TCP on Linux only.
+	ListenBacklog   int  `env:"LISTEN_BACKLOG" envDefault:"0"`
+	ListenReusePort bool `env:"LISTEN_REUSEPORT" envDefault:"false"`
+	// MetricsAddress serves the Prometheus /metrics endpoint over plain HTTP;
+	// empty disables it. Meant for an internal scrape network, not the public
+	// listener.
+	MetricsAddress string `env:"METRICS_ADDRESS"`
+	// CompressResponses defaults outbound gzip compression on for clients
+	// that accept it. Compressed requests are accepted either way; the
+	// encrypted payload streams are never compressed.
+	CompressResponses bool   `env:"COMPRESS_RESPONSES" envDefault:"false"`
+	LogLevel          string `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat         string `env:"LOG_FORMAT" envDefault:"text"`
+
+	DatabaseDSN string `env:"DATABASE_DSN"`
+	// ReadReplicaDSN points read-only queries at a replica, taking list and
+	// get load off the primary. Empty keeps everything on the primary.
+	ReadReplicaDSN    string        `env:"READ_REPLICA_DSN"`
+	DBConnectAttempts int           `env:"DB_CONNECT_ATTEMPTS" envDefault:"5"`
+	DBConnectBackoff  time.Duration `env:"DB_CONNECT_BACKOFF" envDefault:"2s"`
+
+	// StartupCheckAttempts, StartupCheckBackoff and StartupCheckTimeout tune
+	// the per-dependency startup probes: how often a failing check is retried,
+	// how long to wait between retries, and the deadline of one probe.
+	// Transient failures — MinIO still booting, another replica mid-migration
+	// — resolve within a few retries; persistent ones fail startup with the
+	// offending check named in the log.
+	StartupCheckAttempts int           `env:"STARTUP_CHECK_ATTEMPTS" envDefault:"3"`
+	StartupCheckBackoff  time.Duration `env:"STARTUP_CHECK_BACKOFF" envDefault:"2s"`
+	StartupCheckTimeout  time.Duration `env:"STARTUP_CHECK_TIMEOUT" envDefault:"5s"`
+	// RunMigrations applies pending embedded schema migrations at startup,
+	// before the server begins serving.
+	RunMigrations bool `env:"RUN_MIGRATIONS" envDefault:"false"`
+	// StrictSchemaCheck makes startup fail when the schema self-check finds
+	// the request_id conflict index missing, instead of only logging a
+	// warning.
+	StrictSchemaCheck bool `env:"STRICT_SCHEMA_CHECK" envDefault:"false"`
+
+	MinioEndpoint  string   `env:"MINIO_ENDPOINT"`
+	MinioAccessKey string   `env:"MINIO_ACCESS_KEY"`
+	MinioSecretKey string   `env:"MINIO_SECRET_KEY"`
+	MinioBucket    string   `env:"MINIO_BUCKET" envDefault:"gophkeeper"`
+	MinioBuckets   []string `env:"MINIO_BUCKETS" envSeparator:","`
+	MinioUseSSL    bool     `env:"MINIO_USE_SSL" envDefault:"false"`
+	// MinioLifecycleDays expires objects under MinioLifecyclePrefix after the
+	// given number of days. Zero leaves the buckets without a lifecycle rule.
+	MinioLifecycleDays   int    `env:"MINIO_LIFECYCLE_DAYS" envDefault:"0"`
+	MinioLifecyclePrefix string `env:"MINIO_LIFECYCLE_PREFIX"`
+	// ObjectKeyPrefix namespaces new object keys (e.g. "prod/"), keeping
+	// environments apart when they share a bucket and letting lifecycle rules
+	// target one of them. Empty keeps keys directly under the bucket root.
+	ObjectKeyPrefix string `env:"OBJECT_KEY_PREFIX"`
+	// StorageOpTimeout bounds each object-store operation with its own
+	// deadline, independent of the request deadline. For downloads it covers
+	// the whole object read. Zero disables the per-operation deadline.
+	StorageOpTimeout time.Duration `env:"STORAGE_OP_TIMEOUT" envDefault:"2m"`
+
+	JWTSecret       string        `env:"JWT_SECRET"`
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"720h"`
+	// MaxRefreshTokensPerUser caps active refresh tokens per user; issuing
+	// beyond the cap evicts the oldest. Zero disables the cap.
+	MaxRefreshTokensPerUser int `env:"MAX_REFRESH_TOKENS_PER_USER" envDefault:"10"`
+	// TokenLeeway tolerates clock skew between hosts on token expiry checks.
+	// Keep it small: it extends every token's effective lifetime.
+	TokenLeeway time.Duration `env:"TOKEN_LEEWAY" envDefault:"30s"`
+	// AuthMetadataKey and AuthScheme select the metadata header and scheme
+	// prefix the bearer token is read from, for gateways that rename or
+	// strip the authorization header. An empty scheme means the header
+	// carries the bare token.
+	AuthMetadataKey string `env:"AUTH_METADATA_KEY" envDefault:"authorization"`
+	AuthScheme      string `env:"AUTH_SCHEME" envDefault:"Bearer"`
+	// StreamTokenGrace accepts, on streaming methods only, a token expired no
+	// longer than this ago, so a long upload started near expiry can still
+	// begin; streams validate once at start and are never killed mid-stream.
+	// The trade-off: a stolen token stays usable on streams for the grace
+	// past its expiry, so keep it well below ACCESS_TOKEN_TTL. Zero applies
+	// the same strict check as unary methods.
+	StreamTokenGrace time.Duration `env:"STREAM_TOKEN_GRACE" envDefault:"0s"`
+	// MaxStreamsPerUser caps concurrent streaming calls per authenticated
+	// user; each open stream holds goroutines and an object-store session.
+	// Zero disables the cap.
+	MaxStreamsPerUser int `env:"MAX_STREAMS_PER_USER" envDefault:"10"`
+
+	MaxRecordNameLength        int `env:"MAX_RECORD_NAME_LENGTH" envDefault:"255"`
+	MaxRecordDescriptionLength int `env:"MAX_RECORD_DESCRIPTION_LENGTH" envDefault:"4096"`
+	// SanitizeDescriptions strips control characters and HTML-escapes record
+	// descriptions on write. Off by default so client data is stored
+	// verbatim; enable it for deployments with untrusted web frontends.
+	SanitizeDescriptions bool `env:"SANITIZE_DESCRIPTIONS" envDefault:"false"`
+	// Max*PayloadSize cap the inline EncryptedData per record type on the
+	// unary create path; binaries never come through it. Zero disables a cap.
+	MaxLoginPayloadSize int `env:"MAX_LOGIN_PAYLOAD_SIZE" envDefault:"16384"`
+	MaxNotePayloadSize  int `env:"MAX_NOTE_PAYLOAD_SIZE" envDefault:"1048576"`
+	MaxCardPayloadSize  int `env:"MAX_CARD_PAYLOAD_SIZE" envDefault:"16384"`
+	// MaxStreamChunkSize caps the server-side read buffer for binary
+	// downloads; records stored with a larger chunk size are re-chunked on
+	// the wire. Zero disables the clamp.
+	MaxStreamChunkSize int64 `env:"MAX_STREAM_CHUNK_SIZE" envDefault:"1048576"`
+	// DefaultChunkSize applies when binary record metadata omits the chunk
+	// size. MinChunkSize rejects pathologically tiny chunks and
+	// MaxUploadChunkSize clamps oversized ones; zero disables a bound.
+	DefaultChunkSize   int64 `env:"DEFAULT_CHUNK_SIZE" envDefault:"1048576"`
+	MinChunkSize       int64 `env:"MIN_CHUNK_SIZE" envDefault:"4096"`
+	MaxUploadChunkSize int64 `env:"MAX_UPLOAD_CHUNK_SIZE" envDefault:"8388608"`
+	// UploadIdleTimeout aborts a streaming upload when no chunk arrives
+	// within the window; zero disables the timeout.
+	UploadIdleTimeout time.Duration `env:"UPLOAD_IDLE_TIMEOUT" envDefault:"1m"`
+	// MaxInlineBinarySize is the cutoff up to which GetRecord inlines a
+	// binary payload instead of requiring GetRecordStream. Zero disables
+	// inlining.
+	MaxInlineBinarySize int64 `env:"MAX_INLINE_BINARY_SIZE" envDefault:"262144"`
+	// VerifyUploads stats every uploaded object before committing the record
+	// row, failing the create when the object is missing or its size does not
+	// match. Stronger write integrity at the cost of a round trip per upload.
+	VerifyUploads bool `env:"VERIFY_UPLOADS" envDefault:"false"`
+	// MaxInlineStreamPayload lets non-binary records be uploaded through the
+	// streaming path: reassembled payloads up to this size are stored inline,
+	// larger ones are promoted to the object storage. Zero keeps streaming
+	// uploads binary-only.
+	MaxInlineStreamPayload int64 `env:"MAX_INLINE_STREAM_PAYLOAD" envDefault:"262144"`
+	// MaxUnaryResponseSize fails GetRecord with a clear error when the
+	// inline payload would exceed the gRPC send limit, instead of an opaque
+	// transport failure. The default matches gRPC's 4 MiB max message size.
+	MaxUnaryResponseSize int64 `env:"MAX_UNARY_RESPONSE_SIZE" envDefault:"4194304"`
+	// MaxRecordsPerUser caps live records per user; zero means unlimited.
+	MaxRecordsPerUser int `env:"MAX_RECORDS_PER_USER" envDefault:"0"`
+	// MaxTagsPerRecord and MaxTagLength bound the free-form record tags.
+	MaxTagsPerRecord int `env:"MAX_TAGS_PER_RECORD" envDefault:"16"`
+	MaxTagLength     int `env:"MAX_TAG_LENGTH" envDefault:"64"`
+	// AllowedAlgs is the comma-separated allowlist of encryption algorithm
+	// identifiers accepted in record metadata; extend it when clients roll
+	// out a new AEAD. MaxEncryptedKeySize caps the key envelope in bytes.
+	AllowedAlgs         []string `env:"ALLOWED_ALGS" envDefault:"aes-256-gcm,xchacha20-poly1305"`
+	MaxEncryptedKeySize int      `env:"MAX_ENCRYPTED_KEY_SIZE" envDefault:"1024"`
+	// ExposeOwnershipErrors reports access to another user's record as
+	// PermissionDenied instead of NotFound. Leaking record existence this
+	// way is only acceptable on trusted internal deployments.
+	ExposeOwnershipErrors bool `env:"EXPOSE_OWNERSHIP_ERRORS" envDefault:"false"`
+
+	KDFTime uint32 `env:"KDF_TIME" envDefault:"3"`
+	KDFMem  uint32 `env:"KDF_MEM" envDefault:"65536"`
+	KDFPar  uint8  `env:"KDF_PAR" envDefault:"4"`
+	// KDFMin* are the floor below which the issued KDF parameters must never
+	// fall; a policy under the floor fails Validate at startup.
+	KDFMinTime uint32 `env:"KDF_MIN_TIME" envDefault:"2"`
+	KDFMinMem  uint32 `env:"KDF_MIN_MEM" envDefault:"19456"`
+	KDFMinPar  uint8  `env:"KDF_MIN_PAR" envDefault:"1"`
+
+	// PendingSessionTTL is how long a registration or login session stays
+	// valid before the client must restart the SCRAM exchange.
+	PendingSessionTTL time.Duration `env:"PENDING_SESSION_TTL" envDefault:"10m"`
+	// LoginSessionReuseWindow lets GetLoginParams hand a retrying client its
+	// just-created pending session back instead of inserting another row,
+	// but only when the retry carries the identical client nonce. Keep it
+	// short — it is a retry window, not a session lifetime; zero disables
+	// reuse.
+	LoginSessionReuseWindow time.Duration `env:"LOGIN_SESSION_REUSE_WINDOW" envDefault:"0"`
+	// MaxPendingSessions caps the unconsumed, unexpired registration or login
+	// sessions one email may have open at a time, so unauthenticated callers
+	// cannot fill the pending tables. Zero disables the cap.
+	MaxPendingSessions int `env:"MAX_PENDING_SESSIONS" envDefault:"10"`
+	// RegistrationPrivacy hides account existence from unauthenticated
+	// callers: GetRegParams issues normal-looking params for a taken email
+	// and the conflict only surfaces at CompleteReg. Off by default, which
+	// fails fast on the first call but allows enumeration.
+	RegistrationPrivacy bool `env:"REGISTRATION_PRIVACY" envDefault:"false"`
+
+	// TombstoneRetention is how long soft-deleted records (and the stored
+	// objects of binaries) are kept before the purge job hard-deletes them.
+	// PurgeInterval is how often the job runs; zero disables it.
+	TombstoneRetention time.Duration `env:"TOMBSTONE_RETENTION" envDefault:"720h"`
+	PurgeInterval      time.Duration `env:"PURGE_INTERVAL" envDefault:"1h"`
+	// TombstoneAckWindow is the safety window for acknowledgment-driven early
+	// tombstone purging: a tombstone younger than the window is never purged
+	// ahead of TombstoneRetention, even when every known device has
+	// acknowledged it. Zero disables early purging; acknowledgments are still
+	// recorded and excluded from delta responses.
+	TombstoneAckWindow time.Duration `env:"TOMBSTONE_ACK_WINDOW" envDefault:"0"`
+
+	// MaxSyncLookback caps how far back a delta sync cursor may reach; an
+	// older cursor is told to run a full resync instead, bounding the scan an
+	// arbitrarily stale client can trigger. Keep it at or below
+	// TombstoneRetention: past that point tombstones have been purged and a
+	// delta would silently miss deletions anyway. The purge job also prunes
+	// outbox events older than this window, since they can no longer be
+	// served. Zero disables the cap and the pruning.
+	MaxSyncLookback time.Duration `env:"MAX_SYNC_LOOKBACK" envDefault:"720h"`
+
+	// JobConcurrency bounds parallel object-store operations in the purge and
+	// re-encryption jobs: enough workers that a large run does not take
+	// hours, few enough not to overwhelm the storage backend. One runs the
+	// jobs sequentially.
+	JobConcurrency int `env:"JOB_CONCURRENCY" envDefault:"4"`
+
+	// ReencryptObjects runs the one-shot job that server-side copies every
+	// stored object so it is re-written under the buckets' current SSE key.
+	// Heavyweight: it reads every key, so an administrator enables it only
+	// for the deploy following a key rotation.
+	ReencryptObjects bool `env:"REENCRYPT_OBJECTS" envDefault:"false"`
+
+	// PresignedUploads lets clients replace a binary record's contents by
+	// uploading directly to the object store through a presigned URL,
+	// bypassing the server's streaming path; the MinIO endpoint must be
+	// reachable by clients. PresignedPutExpiry bounds how long an issued URL
+	// stays valid.
+	PresignedUploads   bool          `env:"PRESIGNED_UPLOADS" envDefault:"false"`
+	PresignedPutExpiry time.Duration `env:"PRESIGNED_PUT_EXPIRY" envDefault:"15m"`
+
+	// KDFRepairOnStart runs the startup scan that rewrites recoverable
+	// malformed KDF blobs into their canonical serialization and logs the
+	// unrecoverable ones. Cheap and idempotent; an administrator enables it
+	// after corrupt-KDF errors show up in the logs.
+	KDFRepairOnStart bool `env:"KDF_REPAIR_ON_START" envDefault:"false"`
+
+	// LogOpLatency wraps the database and object-store layers with per-call
+	// Debug duration logging; off by default so the hot path does not pay
+	// for it.
+	LogOpLatency bool `env:"LOG_OP_LATENCY" envDefault:"false"`
+
+	// LogSampleRate logs one in N successful RPCs at Info; zero or one logs
+	// every call. Failed RPCs and ones slower than LogSlowThreshold are
+	// always logged, so sampling costs no error or latency visibility.
+	LogSampleRate    int           `env:"LOG_SAMPLE_RATE" envDefault:"1"`
+	LogSlowThreshold time.Duration `env:"LOG_SLOW_THRESHOLD" envDefault:"1s"`
+
+	// ShutdownTimeout is how long shutdown waits for in-flight requests to
+	// drain before stopping the server anyway.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+
+	// EnableReflection registers the gRPC reflection service, exposing the
+	// full schema to anyone who can connect. Useful with grpcurl in
+	// local/dev profiles; keep it off in production.
+	EnableReflection bool `env:"ENABLE_REFLECTION" envDefault:"false"`
+
+	EnableTLS        bool   `env:"ENABLE_TLS" envDefault:"false"`
+	GRPCCertFileName string `env:"GRPC_CERT_FILE_NAME"`
+	GRPCKeyFileName  string `env:"GRPC_KEY_FILE_NAME"`
+	// GRPCCertPEM and GRPCKeyPEM carry the certificate inline, for
+	// secret-manager setups without convenient file mounts. When set they
+	// take precedence over the file names.
+	GRPCCertPEM string `env:"GRPC_CERT_PEM"`
+	GRPCKeyPEM  string `env:"GRPC_KEY_PEM"`
+}
+
+// New parses configuration from the environment.
+func New() (*Config, error) {
+	cfg := &Config{}
+	if err := env.Parse(cfg); err != nil {
+		return nil, fmt.Errorf("parse env: %w", err)
+	}
+	return cfg, nil
+}
+
+// Buckets returns the bucket set for object storage: the sharded list when
+// MINIO_BUCKETS is set, otherwise the single MINIO_BUCKET.
+func (c *Config) Buckets() []string {
+	if len(c.MinioBuckets) > 0 {
+		return c.MinioBuckets
+	}
+	return []string{c.MinioBucket}
+}
+
+// Validate checks that required settings are present and consistent.
+func (c *Config) Validate() error {
+	if c.GRPCNetwork != "tcp" && c.GRPCNetwork != "unix" {
+		return fmt.Errorf("unsupported grpc network %q, want tcp or unix", c.GRPCNetwork)
+	}
+	if c.DatabaseDSN == "" {
+		return errors.New("database dsn is required")
+	}
+	if c.MinioEndpoint == "" {
+		return errors.New("minio endpoint is required")
+	}
+	if c.JWTSecret == "" {
+		return errors.New("jwt secret is required")
+	}
+	if c.EnableTLS {
+		if (c.GRPCCertPEM == "") != (c.GRPCKeyPEM == "") {
+			return errors.New("tls inline cert and key must be set together")
+		}
+		if c.GRPCCertPEM == "" && (c.GRPCCertFileName == "" || c.GRPCKeyFileName == "") {
+			return errors.New("tls enabled but cert or key file is not set")
+		}
+	}
+	if c.KDFTime < c.KDFMinTime || c.KDFMem < c.KDFMinMem || c.KDFPar < c.KDFMinPar {
+		return fmt.Errorf("kdf params (time=%d mem=%d par=%d) below minimum bounds (time=%d mem=%d par=%d)",
+			c.KDFTime, c.KDFMem, c.KDFPar, c.KDFMinTime, c.KDFMinMem, c.KDFMinPar)
+	}
+	return nil
+}
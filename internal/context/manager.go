@@ -0,0 +1,97 @@
+// Package context manages propagation of the authenticated user identity
+// through request contexts.
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+)
+
+const userIDKey = "user_id"
+
+// userIDValueKey is the context key for the value-based user ID storage. A
+// dedicated unexported type cannot collide with keys from other packages.
+type userIDValueKey struct{}
+
+// scopesValueKey is the context key for the token scopes.
+type scopesValueKey struct{}
+
+// Manager stores and retrieves the authenticated user ID. The primary
+// carrier is a plain context value; a copy is also kept in gRPC incoming
+// metadata for client-side tooling that reads the user_id metadata key.
+type Manager struct{}
+
+// New creates a context manager.
+func New() *Manager {
+	return &Manager{}
+}
+
+// SetUserIDValue returns a context carrying the user ID as a context value.
+// Unlike incoming metadata this survives any interceptor ordering: a wrapped
+// stream context propagates values untouched, whereas an interceptor that
+// rebuilds incoming metadata silently drops a metadata-borne ID.
+func (m *Manager) SetUserIDValue(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIDValueKey{}, userID)
+}
+
+// GetUserIDValue extracts the user ID stored by SetUserIDValue.
+func (m *Manager) GetUserIDValue(ctx context.Context) (uuid.UUID, error) {
+	userID, ok := ctx.Value(userIDValueKey{}).(uuid.UUID)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("no user id in context")
+	}
+	return userID, nil
+}
+
+// SetScopesValue returns a context carrying the validated token's scopes, so
+// handlers can make finer decisions than the per-method scope check allows.
+func (m *Manager) SetScopesValue(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesValueKey{}, scopes)
+}
+
+// GetScopesValue extracts the scopes stored by SetScopesValue. Nil means no
+// scopes were stored — a legacy full-access token, or a path that never went
+// through the auth interceptor.
+func (m *Manager) GetScopesValue(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesValueKey{}).([]string)
+	return scopes
+}
+
+// SetUserIDToContext stores the user ID for downstream handlers, as a context
+// value and additionally in incoming metadata.
+func (m *Manager) SetUserIDToContext(ctx context.Context, userID uuid.UUID) context.Context {
+	ctx = m.SetUserIDValue(ctx, userID)
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.New(nil)
+	} else {
+		md = md.Copy()
+	}
+	md.Set(userIDKey, userID.String())
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// GetUserIDFromContext extracts the user ID previously stored by
+// SetUserIDToContext. The context value takes precedence; the metadata copy
+// is a fallback for contexts whose value chain was not preserved.
+func (m *Manager) GetUserIDFromContext(ctx context.Context) (uuid.UUID, error) {
+	if userID, err := m.GetUserIDValue(ctx); err == nil {
+		return userID, nil
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("no metadata in context")
+	}
+	values := md.Get(userIDKey)
+	if len(values) == 0 {
+		return uuid.Nil, fmt.Errorf("no user id in context")
+	}
+	userID, err := uuid.Parse(values[0])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("parse user id: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/dtroode/gophkeeper-api"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+	"github.com/dtroode/gophkeeper-server/internal/service"
+)
+
+// AuthService is the auth business logic the handler exposes.
+type AuthService interface {
+	GetRegParams(ctx context.Context, email string) (*service.RegParams, error)
+	CompleteReg(ctx context.Context, params service.RegComplete) (*model.SessionResult, error)
+	GetLoginParams(ctx context.Context, params service.LoginParams) (*service.LoginChallenge, error)
+	CompleteLogin(ctx context.Context, params service.LoginComplete) (*model.SessionResult, error)
+	Refresh(ctx context.Context, refreshToken string) (*model.SessionResult, error)
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+	CheckEmailRegistered(ctx context.Context, email string) (bool, error)
+	KDFPolicy() service.KDFPolicy
+}
+
+// Auth is the gRPC handler for authentication methods.
+type Auth struct {
+	pb.UnimplementedAuthServer
+	service        AuthService
+	contextManager ContextManager
+	logger         *logger.Logger
+}
+
+// NewAuth creates the auth handler.
+func NewAuth(service AuthService, contextManager ContextManager, l *logger.Logger) *Auth {
+	return &Auth{service: service, contextManager: contextManager, logger: l}
+}
+
+// GetKDFPolicy returns the server's current KDF parameters and the minimums
+// it enforces. Unlike GetRegParams it allocates no pending session, so
+// clients can size their key derivation before committing to register.
+func (h *Auth) GetKDFPolicy(_ context.Context, _ *pb.GetKDFPolicyRequest) (*pb.KDFPolicyResponse, error) {
+	policy := h.service.KDFPolicy()
+	return &pb.KDFPolicyResponse{
+		KdfTime:      policy.Current.Time,
+		KdfMemKib:    policy.Current.MemKiB,
+		KdfPar:       uint32(policy.Current.Par),
+		MinKdfTime:   policy.Minimum.Time,
+		MinKdfMemKib: policy.Minimum.MemKiB,
+		MinKdfPar:    uint32(policy.Minimum.Par),
+	}, nil
+}
+
+// GetRegParams starts registration.
+func (h *Auth) GetRegParams(ctx context.Context, req *pb.GetRegParamsRequest) (*pb.RegParamsResponse, error) {
+	params, err := h.service.GetRegParams(ctx, req.GetEmail())
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.RegParamsResponse{
+		SessionId:   params.SessionID.String(),
+		KdfTime:     params.KDF.Time,
+		KdfMemKib:   params.KDF.MemKiB,
+		KdfPar:      uint32(params.KDF.Par),
+		ServerNonce: params.ServerNonce,
+		ExpiresAt:   params.ExpiresAt.Unix(),
+	}, nil
+}
+
+// CompleteReg finishes registration.
+func (h *Auth) CompleteReg(ctx context.Context, req *pb.CompleteRegRequest) (*pb.SessionResponse, error) {
+	sessionID, err := uuid.Parse(req.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	result, err := h.service.CompleteReg(ctx, service.RegComplete{
+		SessionID: sessionID,
+		Email:     req.GetEmail(),
+		Verifier:  req.GetVerifier(),
+		Salt:      req.GetSalt(),
+		KDF: model.KDFParams{
+			Alg:    "argon2id",
+			Time:   req.GetKdfTime(),
+			MemKiB: req.GetKdfMemKib(),
+			Par:    uint8(req.GetKdfPar()),
+		},
+		ClientProof: req.GetClientProof(),
+	})
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.SessionResponse{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+	}, nil
+}
+
+// GetLoginParams starts login.
+func (h *Auth) GetLoginParams(ctx context.Context, req *pb.GetLoginParamsRequest) (*pb.LoginParamsResponse, error) {
+	challenge, err := h.service.GetLoginParams(ctx, service.LoginParams{
+		Login:       req.GetLogin(),
+		ClientNonce: req.GetClientNonce(),
+	})
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.LoginParamsResponse{
+		SessionId:   challenge.SessionID.String(),
+		Salt:        challenge.Salt,
+		KdfTime:     challenge.KDF.Time,
+		KdfMemKib:   challenge.KDF.MemKiB,
+		KdfPar:      uint32(challenge.KDF.Par),
+		ServerNonce: challenge.ServerNonce,
+		ExpiresAt:   challenge.ExpiresAt.Unix(),
+	}, nil
+}
+
+// CompleteLogin finishes login.
+func (h *Auth) CompleteLogin(ctx context.Context, req *pb.CompleteLoginRequest) (*pb.SessionResponse, error) {
+	sessionID, err := uuid.Parse(req.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	result, err := h.service.CompleteLogin(ctx, service.LoginComplete{
+		SessionID:   sessionID,
+		ClientProof: req.GetClientProof(),
+		Scopes:      req.GetScopes(),
+	})
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.SessionResponse{
+		AccessToken:           result.AccessToken,
+		RefreshToken:          result.RefreshToken,
+		KdfUpgradeRecommended: result.KDFUpgradeRecommended,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+func (h *Auth) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.SessionResponse, error) {
+	result, err := h.service.Refresh(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.SessionResponse{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+	}, nil
+}
+
+// RevokeAllSessions revokes every session of the authenticated user.
+func (h *Auth) RevokeAllSessions(ctx context.Context, _ *pb.RevokeAllSessionsRequest) (*pb.RevokeAllSessionsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.service.RevokeAllSessions(ctx, userID); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.RevokeAllSessionsResponse{Success: true}, nil
+}
+
+// CheckEmailRegistered reports whether an email has an account. The method
+// requires a token with the admin scope — the interceptor enforces that — so
+// it gives support tooling a direct answer without weakening the public
+// registration flow's enumeration resistance.
+func (h *Auth) CheckEmailRegistered(ctx context.Context, req *pb.CheckEmailRegisteredRequest) (*pb.CheckEmailRegisteredResponse, error) {
+	registered, err := h.service.CheckEmailRegistered(ctx, req.GetEmail())
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.CheckEmailRegisteredResponse{Registered: registered}, nil
+}
+
+// handleError maps service errors to gRPC statuses.
+func (h *Auth) handleError(err error) error {
+	var apiErr *apiErrors.APIError
+	if errors.As(err, &apiErr) {
+		return statusWithReason(apiErr, status.Newf(apiErr.GRPCCode, apiErr.Template, apiErr.Args...))
+	}
+	h.logger.Error("internal error", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
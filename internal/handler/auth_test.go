@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+func TestAuthHandleError_RendersTemplateArgs(t *testing.T) {
+	h := &Auth{logger: logger.NewNoop()}
+
+	err := h.handleError(apiErrors.NewErrEmailIsTaken("user@example.com"))
+
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("err = %v, want a gRPC status", err)
+	}
+	if st.Code() != codes.AlreadyExists {
+		t.Errorf("code = %s, want %s", st.Code(), codes.AlreadyExists)
+	}
+	if st.Message() != "email user@example.com is taken" {
+		t.Errorf("message = %q, want the rendered template", st.Message())
+	}
+}
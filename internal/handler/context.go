@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dtroode/gophkeeper-server/internal/middleware"
+)
+
+// authenticatedUserID returns the user ID the auth interceptor stored in the
+// context, mapping its absence to the canonical Unauthenticated status. Every
+// handler method must obtain its user ID through this helper instead of
+// talking to the context manager directly, so a new endpoint cannot forget
+// the check or invent a different error mapping. Service-level ownership
+// checks stay in place; this is the first line, not the only one.
+func authenticatedUserID(ctx context.Context, cm ContextManager) (uuid.UUID, error) {
+	userID, err := cm.GetUserIDValue(ctx)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "user not authenticated")
+	}
+	return userID, nil
+}
+
+// hasWriteScope reports whether the validated token may mutate records: an
+// explicit records:write grant, or a legacy unscoped token, mirroring how
+// middleware.RequireScope treats an empty scope list. Used to gate mutating
+// request fields on methods whose per-method scope is read-only.
+func hasWriteScope(ctx context.Context, cm ContextManager) bool {
+	scopes := cm.GetScopesValue(ctx)
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if scope == middleware.ScopeRecordsWrite {
+			return true
+		}
+	}
+	return false
+}
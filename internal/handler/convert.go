@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+
+	pb "github.com/dtroode/gophkeeper-api"
+
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// recordTypePairs is the single source of truth for the proto<->domain record
+// type mapping. New record types are added here and nowhere else; both lookup
+// maps below are derived from it.
+var recordTypePairs = []struct {
+	proto  pb.RecordType
+	domain model.RecordType
+}{
+	{pb.RecordType_RECORD_TYPE_LOGIN, model.RecordTypeLogin},
+	{pb.RecordType_RECORD_TYPE_NOTE, model.RecordTypeNote},
+	{pb.RecordType_RECORD_TYPE_CARD, model.RecordTypeCard},
+	{pb.RecordType_RECORD_TYPE_BINARY, model.RecordTypeBinary},
+}
+
+var (
+	protoToRecordType = make(map[pb.RecordType]model.RecordType, len(recordTypePairs))
+	recordTypeToProto = make(map[model.RecordType]pb.RecordType, len(recordTypePairs))
+)
+
+func init() {
+	for _, pair := range recordTypePairs {
+		protoToRecordType[pair.proto] = pair.domain
+		recordTypeToProto[pair.domain] = pair.proto
+	}
+}
+
+// convertProtoRecordType maps a proto record type to the domain type.
+func convertProtoRecordType(t pb.RecordType) (model.RecordType, bool) {
+	domain, ok := protoToRecordType[t]
+	return domain, ok
+}
+
+// convertRecordTypeToProto maps a domain record type to the proto type. An
+// unknown type is an error, not a fallback: a stored record with a type this
+// build does not know about must not be silently relabeled.
+func convertRecordTypeToProto(t model.RecordType) (pb.RecordType, error) {
+	proto, ok := recordTypeToProto[t]
+	if !ok {
+		return pb.RecordType_RECORD_TYPE_UNSPECIFIED, fmt.Errorf("unknown record type %q", t)
+	}
+	return proto, nil
+}
+
+// convertRecordToMetadata builds the proto metadata for a record.
+func convertRecordToMetadata(record *model.Record) (*pb.RecordMetadata, error) {
+	var requestID string
+	if record.RequestID != nil {
+		requestID = record.RequestID.String()
+	}
+
+	recordType, err := convertRecordTypeToProto(record.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RecordMetadata{
+		Id:           record.ID.String(),
+		RequestId:    requestID,
+		Type:         recordType,
+		Name:         record.Name,
+		Description:  record.Description,
+		EncryptedKey: record.EncryptedKey,
+		Alg:          record.Alg,
+		Compression:  record.Compression,
+		Tags:         record.Tags,
+		Pinned:       record.Pinned,
+		ChunkSize:    record.EncryptedChunkSize,
+		FileSize:     record.FileSize,
+		Etag:         record.ETag,
+		Version:      record.Version,
+		CreatedAt:    record.CreatedAt.Unix(),
+		UpdatedAt:    record.UpdatedAt.Unix(),
+	}, nil
+}
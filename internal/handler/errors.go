@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+)
+
+// statusWithReason attaches a google.rpc.ErrorInfo detail carrying the error's
+// stable reason, so clients can branch on machine-readable identifiers instead
+// of matching message substrings. Errors without a reason, and statuses the
+// detail cannot be attached to, are returned with code and message only —
+// existing clients that read just those keep working.
+func statusWithReason(apiErr *apiErrors.APIError, st *status.Status) error {
+	if apiErr.Reason == "" {
+		return st.Err()
+	}
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: apiErr.Reason,
+		Domain: apiErrors.Domain,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
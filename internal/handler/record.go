@@ -0,0 +1,825 @@
+// Package handler implements the gRPC API on top of the services.
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/dtroode/gophkeeper-api"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+	"github.com/dtroode/gophkeeper-server/internal/service"
+)
+
+// RecordService is the record business logic the handler exposes.
+type RecordService interface {
+	CreateRecord(ctx context.Context, userID uuid.UUID, params service.CreateRecordParams) (*model.Record, bool, error)
+	CreateRecordStream(ctx context.Context, userID uuid.UUID, params service.CreateRecordParams, chunks service.ChunkReceiver) (*model.Record, bool, error)
+	GetRecord(ctx context.Context, userID, recordID uuid.UUID, includeDeleted bool) (*model.Record, error)
+	GetByIDs(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]model.Record, error)
+	StreamRecordToClient(ctx context.Context, userID, recordID uuid.UUID, sender service.ChunkSender) error
+	ExportRecords(ctx context.Context, userID uuid.UUID, sender service.ExportSender) error
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Record, error)
+	ListRecordIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	GetByTag(ctx context.Context, userID uuid.UUID, tag string) ([]model.Record, error)
+	SetRecordTags(ctx context.Context, userID, recordID uuid.UUID, tags []string) error
+	GetPinnedRecords(ctx context.Context, userID uuid.UUID) ([]model.Record, error)
+	SetRecordPinned(ctx context.Context, userID, recordID uuid.UUID, pinned bool) error
+	ChangeRecordType(ctx context.Context, userID, recordID uuid.UUID, newType model.RecordType) error
+	ListRecordsDelta(ctx context.Context, userID uuid.UUID, updatedAfter time.Time, includeDeleted bool, ackedBefore time.Time) ([]model.Record, []model.Tombstone, error)
+	AcknowledgeTombstones(ctx context.Context, userID uuid.UUID, deviceID string, ackedBefore time.Time) error
+	WatchRecords(ctx context.Context, userID uuid.UUID, afterSeq int64, sender service.EventSender) error
+	TransferRecord(ctx context.Context, fromUserID, recordID, toUserID uuid.UUID) error
+	RekeyRecord(ctx context.Context, userID, recordID uuid.UUID, newEncryptedKey []byte, newAlg string) error
+	GetPresignedPutURL(ctx context.Context, userID, recordID uuid.UUID) (string, time.Time, error)
+	FinalizeBinaryUpdate(ctx context.Context, userID, recordID uuid.UUID) (*model.Record, error)
+	DeleteRecord(ctx context.Context, userID, recordID uuid.UUID, expectedVersion int64, purge bool) error
+	BatchDeleteRecords(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]service.BatchDeleteResult, error)
+	ImportRecords(ctx context.Context, userID uuid.UUID, items []service.ImportRecordItem, strategy service.ImportStrategy) ([]service.ImportResult, error)
+	GetStorageUsage(ctx context.Context, userID uuid.UUID) (*service.StorageUsageReport, error)
+}
+
+// ContextManager retrieves the authenticated user ID and the token scopes
+// from the request context.
+type ContextManager interface {
+	GetUserIDValue(ctx context.Context) (uuid.UUID, error)
+	GetScopesValue(ctx context.Context) []string
+}
+
+// Record is the gRPC handler for record methods.
+type Record struct {
+	pb.UnimplementedRecordsServer
+	service        RecordService
+	contextManager ContextManager
+	logger         *logger.Logger
+}
+
+// NewRecord creates the record handler.
+func NewRecord(service RecordService, contextManager ContextManager, l *logger.Logger) *Record {
+	return &Record{service: service, contextManager: contextManager, logger: l}
+}
+
+// CreateRecord creates a non-binary record with an inline payload.
+func (h *Record) CreateRecord(ctx context.Context, req *pb.CreateRecordRequest) (*pb.CreateRecordResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := h.extractCreateParams(req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	params.EncryptedData = req.GetEncryptedData()
+	// The flag lives on both the request and the shared metadata message;
+	// either one set must make the call a dry run, never a real create.
+	params.DryRun = params.DryRun || req.GetDryRun()
+
+	headerKey, err := idempotencyKeyFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if headerKey != nil {
+		if params.RequestID != nil && *params.RequestID != *headerKey {
+			return nil, status.Error(codes.InvalidArgument, "idempotency-key header conflicts with metadata request id")
+		}
+		params.RequestID = headerKey
+	}
+
+	record, wasDuplicate, err := h.service.CreateRecord(ctx, userID, *params)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	// Dry-run validations succeed without a created record.
+	if record == nil {
+		return &pb.CreateRecordResponse{Success: true}, nil
+	}
+	recordMetadata, err := convertRecordToMetadata(record)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.CreateRecordResponse{
+		Success:      true,
+		WasDuplicate: wasDuplicate,
+		Record:       recordMetadata,
+	}, nil
+}
+
+// CreateRecordStream creates a record from a client chunk stream. The first
+// message must carry metadata, the following ones carry payload chunks.
+func (h *Record) CreateRecordStream(stream pb.Records_CreateRecordStreamServer) error {
+	ctx := stream.Context()
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return err
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "failed to receive metadata")
+	}
+	metadata := first.GetMetadata()
+	if metadata == nil {
+		return status.Error(codes.InvalidArgument, "first message must contain metadata")
+	}
+	params, err := h.extractCreateParams(metadata)
+	if err != nil {
+		return err
+	}
+
+	record, wasDuplicate, err := h.service.CreateRecordStream(ctx, userID, *params, &streamChunkReceiver{stream: stream})
+	if err != nil {
+		return h.handleError(err)
+	}
+	if record == nil {
+		return stream.SendAndClose(&pb.CreateRecordResponse{Success: true})
+	}
+	recordMetadata, err := convertRecordToMetadata(record)
+	if err != nil {
+		return h.handleError(err)
+	}
+	return stream.SendAndClose(&pb.CreateRecordResponse{
+		Success:      true,
+		WasDuplicate: wasDuplicate,
+		Record:       recordMetadata,
+	})
+}
+
+// GetRecord returns a record's metadata and inline payload.
+func (h *Record) GetRecord(ctx context.Context, req *pb.GetRecordRequest) (*pb.GetRecordResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	record, err := h.service.GetRecord(ctx, userID, recordID, req.GetIncludeDeleted())
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	recordMetadata, err := convertRecordToMetadata(record)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.GetRecordResponse{
+		Metadata:      recordMetadata,
+		EncryptedData: record.EncryptedData,
+	}, nil
+}
+
+// BatchGetRecords returns a specific set of records by ID. Missing and
+// foreign IDs are omitted from the response.
+func (h *Record) BatchGetRecords(ctx context.Context, req *pb.BatchGetRecordsRequest) (*pb.BatchGetRecordsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.GetIds()))
+	for _, raw := range req.GetIds() {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid record id")
+		}
+		ids = append(ids, id)
+	}
+
+	records, err := h.service.GetByIDs(ctx, userID, ids)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+
+	resp := &pb.BatchGetRecordsResponse{}
+	for i := range records {
+		recordMetadata, err := convertRecordToMetadata(&records[i])
+		if err != nil {
+			return nil, h.handleError(err)
+		}
+		resp.Records = append(resp.Records, &pb.Record{
+			Metadata:      recordMetadata,
+			EncryptedData: records[i].EncryptedData,
+		})
+	}
+	return resp, nil
+}
+
+// GetRecordStream streams a record's metadata and payload chunks.
+func (h *Record) GetRecordStream(req *pb.GetRecordRequest, stream pb.Records_GetRecordStreamServer) error {
+	ctx := stream.Context()
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	if err := h.service.StreamRecordToClient(ctx, userID, recordID, &streamChunkSender{stream: stream}); err != nil {
+		return h.handleError(err)
+	}
+	return nil
+}
+
+// ExportRecords streams every record of the user for a backup: metadata then
+// chunks per record, with the last-chunk flag delimiting records. Records
+// whose payload is unavailable are reported inline and skipped.
+func (h *Record) ExportRecords(req *pb.ExportRecordsRequest, stream pb.Records_ExportRecordsServer) error {
+	ctx := stream.Context()
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.ExportRecords(ctx, userID, &exportStreamSender{stream: stream}); err != nil {
+		return h.handleError(err)
+	}
+	return nil
+}
+
+// ListRecords returns either all records of the user or the delta since the
+// given timestamp. Clients should state their intent via SyncMode: FULL lists
+// live records, DELTA requires a positive UpdatedAfter. When the mode is
+// unset the legacy inference applies: UpdatedAfter > 0 requests a delta since
+// that time; UpdatedAfter == 0 lists live records; negative timestamps are
+// rejected. IncludeDeleted with a zero UpdatedAfter scans the whole tombstone
+// history, so it additionally requires the explicit FullSync flag instead of
+// running that scan by accident. AckDeletedBefore reports tombstones the
+// client has already applied: they are excluded from the response, and once
+// every known device of the user has acknowledged a tombstone it becomes
+// eligible for early purging. It requires a DeviceId stable across syncs.
+func (h *Record) ListRecords(ctx context.Context, req *pb.ListRecordsRequest) (*pb.ListRecordsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUpdatedAfter() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "updated_after must not be negative")
+	}
+	if req.GetAckDeletedBefore() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ack_deleted_before must not be negative")
+	}
+	if req.GetAckDeletedBefore() > 0 && req.GetDeviceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "ack_deleted_before requires a device_id")
+	}
+	// The explicit mode only tightens validation; the dispatch below stays
+	// shared with legacy clients that leave the mode unset.
+	switch req.GetSyncMode() {
+	case pb.SyncMode_SYNC_MODE_FULL:
+		if req.GetUpdatedAfter() > 0 {
+			return nil, status.Error(codes.InvalidArgument, "full sync mode cannot be combined with updated_after")
+		}
+		if req.GetIncludeDeleted() {
+			return nil, status.Error(codes.InvalidArgument, "full sync mode returns live records only")
+		}
+	case pb.SyncMode_SYNC_MODE_DELTA:
+		if req.GetUpdatedAfter() <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "delta sync mode requires a positive updated_after")
+		}
+	}
+
+	var ackedBefore time.Time
+	if req.GetAckDeletedBefore() > 0 {
+		// Acknowledgment can hard-delete fully-acked tombstones and their
+		// backing objects, so this field needs write scope even though the
+		// method itself is read-only.
+		if !hasWriteScope(ctx, h.contextManager) {
+			return nil, status.Error(codes.PermissionDenied, "ack_deleted_before requires scope records:write")
+		}
+		ackedBefore = time.Unix(req.GetAckDeletedBefore(), 0)
+		if err := h.service.AcknowledgeTombstones(ctx, userID, req.GetDeviceId(), ackedBefore); err != nil {
+			return nil, h.handleError(err)
+		}
+	}
+
+	var (
+		records    []model.Record
+		tombstones []model.Tombstone
+	)
+	switch {
+	case req.GetTag() != "":
+		// The tag filter applies to full listings only; combining it with
+		// delta sync would make tombstone semantics ambiguous.
+		if req.GetUpdatedAfter() > 0 || req.GetIncludeDeleted() {
+			return nil, status.Error(codes.InvalidArgument, "tag filter cannot be combined with delta sync")
+		}
+		records, err = h.service.GetByTag(ctx, userID, req.GetTag())
+	case req.GetOnlyPinned():
+		// Like the tag filter, the pinned filter applies to full listings
+		// only.
+		if req.GetUpdatedAfter() > 0 || req.GetIncludeDeleted() {
+			return nil, status.Error(codes.InvalidArgument, "only_pinned cannot be combined with delta sync")
+		}
+		records, err = h.service.GetPinnedRecords(ctx, userID)
+	case req.GetUpdatedAfter() > 0:
+		updatedAfter := time.Unix(req.GetUpdatedAfter(), 0)
+		records, tombstones, err = h.service.ListRecordsDelta(ctx, userID, updatedAfter, req.GetIncludeDeleted(), ackedBefore)
+	case req.GetIncludeDeleted():
+		if !req.GetFullSync() {
+			return nil, status.Error(codes.InvalidArgument, "include_deleted without updated_after requires full_sync")
+		}
+		records, tombstones, err = h.service.ListRecordsDelta(ctx, userID, time.Unix(0, 0), true, ackedBefore)
+	default:
+		records, err = h.service.GetByUserID(ctx, userID)
+	}
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+
+	// Both slices marshal as empty arrays rather than null when there is
+	// nothing to list.
+	metadata := make([]*pb.RecordMetadata, 0, len(records))
+	for i := range records {
+		recordMetadata, err := convertRecordToMetadata(&records[i])
+		if err != nil {
+			return nil, h.handleError(err)
+		}
+		metadata = append(metadata, recordMetadata)
+	}
+	protoTombstones := make([]*pb.Tombstone, 0, len(tombstones))
+	for _, t := range tombstones {
+		protoTombstones = append(protoTombstones, &pb.Tombstone{
+			Id:        t.ID.String(),
+			DeletedAt: t.DeletedAt.Unix(),
+		})
+	}
+
+	return &pb.ListRecordsResponse{
+		Records:    metadata,
+		Tombstones: protoTombstones,
+		ServerTime: time.Now().Unix(),
+	}, nil
+}
+
+// ListRecordIDs returns just the IDs of the user's live records plus the
+// server time, a lightweight index for cache integrity reconciliation.
+func (h *Record) ListRecordIDs(ctx context.Context, req *pb.ListRecordIDsRequest) (*pb.ListRecordIDsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := h.service.ListRecordIDs(ctx, userID)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+
+	resp := &pb.ListRecordIDsResponse{
+		ServerTime: time.Now().Unix(),
+		Ids:        make([]string, 0, len(ids)),
+	}
+	for _, id := range ids {
+		resp.Ids = append(resp.Ids, id.String())
+	}
+	return resp, nil
+}
+
+// WatchRecords streams record change events to the client, starting after the
+// cursor it last acknowledged.
+func (h *Record) WatchRecords(req *pb.WatchRecordsRequest, stream pb.Records_WatchRecordsServer) error {
+	ctx := stream.Context()
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.WatchRecords(ctx, userID, req.GetAfterSeq(), &streamEventSender{stream: stream}); err != nil {
+		return h.handleError(err)
+	}
+	return nil
+}
+
+// TransferRecord moves a record to another user. Only the current owner may
+// transfer; the caller's identity comes from the authenticated context.
+func (h *Record) TransferRecord(ctx context.Context, req *pb.TransferRecordRequest) (*pb.TransferRecordResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+	toUserID, err := uuid.Parse(req.GetToUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid target user id")
+	}
+
+	if err := h.service.TransferRecord(ctx, userID, recordID, toUserID); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.TransferRecordResponse{Success: true}, nil
+}
+
+// RekeyRecord replaces a record's encrypted data key and algorithm after a
+// client-side master-key rotation, leaving the payload untouched.
+func (h *Record) RekeyRecord(ctx context.Context, req *pb.RekeyRecordRequest) (*pb.RekeyRecordResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	if err := h.service.RekeyRecord(ctx, userID, recordID, req.GetEncryptedKey(), req.GetAlg()); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.RekeyRecordResponse{Success: true}, nil
+}
+
+// GetStorageUsage returns the authenticated user's aggregate storage usage:
+// total stored bytes, record counts per type, and the configured record
+// quota (zero means unlimited).
+func (h *Record) GetStorageUsage(ctx context.Context, _ *pb.GetStorageUsageRequest) (*pb.GetStorageUsageResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := h.service.GetStorageUsage(ctx, userID)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	countByType := make(map[string]int64, len(report.CountByType))
+	for recordType, count := range report.CountByType {
+		countByType[string(recordType)] = int64(count)
+	}
+	return &pb.GetStorageUsageResponse{
+		TotalBytes:   report.TotalBytes,
+		TotalRecords: int64(report.TotalRecords),
+		CountByType:  countByType,
+		MaxRecords:   int64(report.MaxRecords),
+	}, nil
+}
+
+// GetPresignedPutURL returns a URL the client can PUT a binary record's
+// replacement contents to directly, bypassing the server. The record's
+// metadata stays stale until the client calls FinalizeBinaryUpdate.
+func (h *Record) GetPresignedPutURL(ctx context.Context, req *pb.GetPresignedPutURLRequest) (*pb.GetPresignedPutURLResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	url, expiresAt, err := h.service.GetPresignedPutURL(ctx, userID, recordID)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.GetPresignedPutURLResponse{Url: url, ExpiresAt: expiresAt.Unix()}, nil
+}
+
+// FinalizeBinaryUpdate reconciles a record's metadata with its stored object
+// after a presigned upload and returns the refreshed metadata.
+func (h *Record) FinalizeBinaryUpdate(ctx context.Context, req *pb.FinalizeBinaryUpdateRequest) (*pb.FinalizeBinaryUpdateResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	record, err := h.service.FinalizeBinaryUpdate(ctx, userID, recordID)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	recordMetadata, err := convertRecordToMetadata(record)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.FinalizeBinaryUpdateResponse{Metadata: recordMetadata}, nil
+}
+
+// DeleteRecord soft-deletes a record. The purge flag additionally removes a
+// binary record's stored object immediately instead of leaving it to the
+// retention window, for compliance deletions.
+func (h *Record) DeleteRecord(ctx context.Context, req *pb.DeleteRecordRequest) (*pb.DeleteRecordResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	if err := h.service.DeleteRecord(ctx, userID, recordID, req.GetExpectedVersion(), req.GetPurge()); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.DeleteRecordResponse{Success: true}, nil
+}
+
+// SetRecordTags replaces a record's tags.
+func (h *Record) SetRecordTags(ctx context.Context, req *pb.SetRecordTagsRequest) (*pb.SetRecordTagsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	if err := h.service.SetRecordTags(ctx, userID, recordID, req.GetTags()); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.SetRecordTagsResponse{Success: true}, nil
+}
+
+// SetRecordPinned sets or clears a record's pinned flag.
+func (h *Record) SetRecordPinned(ctx context.Context, req *pb.SetRecordPinnedRequest) (*pb.SetRecordPinnedResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+
+	if err := h.service.SetRecordPinned(ctx, userID, recordID, req.GetPinned()); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.SetRecordPinnedResponse{Success: true}, nil
+}
+
+// ChangeRecordType converts a record to another type. Conversions to or from
+// binary are rejected, since only binary payloads are object-backed.
+func (h *Record) ChangeRecordType(ctx context.Context, req *pb.ChangeRecordTypeRequest) (*pb.ChangeRecordTypeResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+	recordID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid record id")
+	}
+	newType, ok := convertProtoRecordType(req.GetNewType())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid record type")
+	}
+
+	if err := h.service.ChangeRecordType(ctx, userID, recordID, newType); err != nil {
+		return nil, h.handleError(err)
+	}
+	return &pb.ChangeRecordTypeResponse{Success: true}, nil
+}
+
+// BatchDeleteRecords soft-deletes a set of records in a single transaction
+// and reports a per-ID outcome. Stored objects of binary records are removed
+// later by the purge job.
+func (h *Record) BatchDeleteRecords(ctx context.Context, req *pb.BatchDeleteRecordsRequest) (*pb.BatchDeleteRecordsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.GetIds()))
+	for _, raw := range req.GetIds() {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid record id")
+		}
+		ids = append(ids, id)
+	}
+
+	results, err := h.service.BatchDeleteRecords(ctx, userID, ids)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+
+	resp := &pb.BatchDeleteRecordsResponse{}
+	for _, result := range results {
+		resp.Results = append(resp.Results, &pb.BatchDeleteResult{
+			Id:     result.RecordID.String(),
+			Status: string(result.Status),
+		})
+	}
+	return resp, nil
+}
+
+// ImportRecords merges a batch of client-side records into the user's set,
+// matching by request id. An empty strategy defaults to server_wins, the
+// conservative choice for a first sync from a new device.
+func (h *Record) ImportRecords(ctx context.Context, req *pb.ImportRecordsRequest) (*pb.ImportRecordsResponse, error) {
+	userID, err := authenticatedUserID(ctx, h.contextManager)
+	if err != nil {
+		return nil, err
+	}
+
+	var strategy service.ImportStrategy
+	switch req.GetStrategy() {
+	case "", string(service.ImportServerWins):
+		strategy = service.ImportServerWins
+	case string(service.ImportClientWins):
+		strategy = service.ImportClientWins
+	case string(service.ImportNewestWins):
+		strategy = service.ImportNewestWins
+	default:
+		return nil, status.Error(codes.InvalidArgument, "unknown import strategy")
+	}
+
+	items := make([]service.ImportRecordItem, 0, len(req.GetRecords()))
+	for _, record := range req.GetRecords() {
+		params, err := h.extractCreateParams(record.GetMetadata())
+		if err != nil {
+			return nil, err
+		}
+		if params.RequestID == nil {
+			return nil, status.Error(codes.InvalidArgument, "import records require a request id")
+		}
+		params.EncryptedData = record.GetEncryptedData()
+		item := service.ImportRecordItem{CreateRecordParams: *params}
+		if record.GetUpdatedAt() > 0 {
+			item.UpdatedAt = time.Unix(record.GetUpdatedAt(), 0)
+		}
+		items = append(items, item)
+	}
+
+	results, err := h.service.ImportRecords(ctx, userID, items, strategy)
+	if err != nil {
+		return nil, h.handleError(err)
+	}
+
+	resp := &pb.ImportRecordsResponse{}
+	for _, result := range results {
+		resp.Results = append(resp.Results, &pb.ImportRecordResult{
+			Id:     result.RecordID.String(),
+			Status: string(result.Status),
+		})
+	}
+	return resp, nil
+}
+
+// extractCreateParams converts proto metadata into service create params.
+func (h *Record) extractCreateParams(metadata *pb.RecordMetadata) (*service.CreateRecordParams, error) {
+	if metadata == nil {
+		return nil, status.Error(codes.InvalidArgument, "metadata is required")
+	}
+	recordType, ok := convertProtoRecordType(metadata.GetType())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown record type")
+	}
+
+	var requestID *uuid.UUID
+	if metadata.GetRequestId() != "" {
+		parsed, err := uuid.Parse(metadata.GetRequestId())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid request id")
+		}
+		requestID = &parsed
+	}
+
+	var originalCreatedAt *time.Time
+	if metadata.GetOriginalCreatedAt() > 0 {
+		t := time.Unix(metadata.GetOriginalCreatedAt(), 0)
+		originalCreatedAt = &t
+	}
+
+	return &service.CreateRecordParams{
+		RequestID:         requestID,
+		Type:              recordType,
+		Name:              metadata.GetName(),
+		Description:       metadata.GetDescription(),
+		EncryptedKey:      metadata.GetEncryptedKey(),
+		Alg:               metadata.GetAlg(),
+		Compression:       metadata.GetCompression(),
+		Tags:              metadata.GetTags(),
+		Pinned:            metadata.GetPinned(),
+		ChunkSize:         metadata.GetChunkSize(),
+		OriginalCreatedAt: originalCreatedAt,
+		DryRun:            metadata.GetDryRun(),
+	}, nil
+}
+
+// idempotencyKeyFromContext reads the Idempotency-Key metadata header, letting
+// REST-style clients deduplicate creates without a request_id in the record
+// metadata. A missing header returns nil, a malformed one an error.
+func idempotencyKeyFromContext(ctx context.Context) (*uuid.UUID, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	values := md.Get("idempotency-key")
+	if len(values) == 0 {
+		return nil, nil
+	}
+	id, err := uuid.Parse(values[0])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid idempotency-key header")
+	}
+	return &id, nil
+}
+
+// handleError maps service errors to gRPC statuses.
+func (h *Record) handleError(err error) error {
+	var apiErr *apiErrors.APIError
+	if errors.As(err, &apiErr) {
+		return statusWithReason(apiErr, status.Newf(apiErr.GRPCCode, apiErr.Template, apiErr.Args...))
+	}
+	h.logger.Error("internal error", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// streamChunkReceiver adapts the gRPC upload stream to service.ChunkReceiver.
+type streamChunkReceiver struct {
+	stream pb.Records_CreateRecordStreamServer
+}
+
+// Recv returns the next payload chunk from the stream.
+func (r *streamChunkReceiver) Recv() ([]byte, error) {
+	msg, err := r.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.GetChunk(), nil
+}
+
+// streamEventSender adapts the gRPC watch stream to service.EventSender.
+type streamEventSender struct {
+	stream pb.Records_WatchRecordsServer
+}
+
+// SendEvent sends one record change event.
+func (s *streamEventSender) SendEvent(event model.RecordEvent) error {
+	return s.stream.Send(&pb.RecordEvent{
+		Seq:        event.Seq,
+		RecordId:   event.RecordID.String(),
+		EventType:  string(event.EventType),
+		OccurredAt: event.OccurredAt.Unix(),
+	})
+}
+
+// exportStreamSender adapts the gRPC export stream to service.ExportSender.
+type exportStreamSender struct {
+	stream pb.Records_ExportRecordsServer
+}
+
+// SendMetadata sends the metadata message opening a record's section.
+func (s *exportStreamSender) SendMetadata(record *model.Record) error {
+	recordMetadata, err := convertRecordToMetadata(record)
+	if err != nil {
+		return err
+	}
+	return s.stream.Send(&pb.ExportRecordsResponse{
+		Payload: &pb.ExportRecordsResponse_Metadata{Metadata: recordMetadata},
+	})
+}
+
+// SendChunk sends a payload chunk, marking the last one of a record.
+func (s *exportStreamSender) SendChunk(data []byte, last bool) error {
+	return s.stream.Send(&pb.ExportRecordsResponse{
+		Payload: &pb.ExportRecordsResponse_Chunk{Chunk: &pb.RecordChunk{Data: data, Last: last}},
+	})
+}
+
+// SendRecordError reports a record whose payload could not be read.
+func (s *exportStreamSender) SendRecordError(recordID uuid.UUID, message string) error {
+	return s.stream.Send(&pb.ExportRecordsResponse{
+		Payload: &pb.ExportRecordsResponse_Error{Error: &pb.ExportRecordError{
+			RecordId: recordID.String(),
+			Message:  message,
+		}},
+	})
+}
+
+// streamChunkSender adapts the gRPC download stream to service.ChunkSender.
+type streamChunkSender struct {
+	stream pb.Records_GetRecordStreamServer
+}
+
+// SendMetadata sends the record metadata message.
+func (s *streamChunkSender) SendMetadata(record *model.Record) error {
+	recordMetadata, err := convertRecordToMetadata(record)
+	if err != nil {
+		return err
+	}
+	return s.stream.Send(&pb.GetRecordStreamResponse{
+		Payload: &pb.GetRecordStreamResponse_Metadata{Metadata: recordMetadata},
+	})
+}
+
+// SendChunk sends a payload chunk, marking the last one.
+func (s *streamChunkSender) SendChunk(data []byte, last bool) error {
+	return s.stream.Send(&pb.GetRecordStreamResponse{
+		Payload: &pb.GetRecordStreamResponse_Chunk{Chunk: &pb.RecordChunk{Data: data, Last: last}},
+	})
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	pb "github.com/dtroode/gophkeeper-api"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+	"github.com/dtroode/gophkeeper-server/internal/service"
+)
+
+// stubContextManager returns a fixed authenticated user with no scopes.
+type stubContextManager struct {
+	userID uuid.UUID
+}
+
+func (s *stubContextManager) GetUserIDValue(context.Context) (uuid.UUID, error) {
+	return s.userID, nil
+}
+
+func (s *stubContextManager) GetScopesValue(context.Context) []string {
+	return nil
+}
+
+// stubRecordService records the params CreateRecord was called with; every
+// other method panics via the embedded nil interface.
+type stubRecordService struct {
+	RecordService
+	createParams *service.CreateRecordParams
+}
+
+func (s *stubRecordService) CreateRecord(_ context.Context, _ uuid.UUID, params service.CreateRecordParams) (*model.Record, bool, error) {
+	s.createParams = &params
+	if params.DryRun {
+		return nil, false, nil
+	}
+	return &model.Record{ID: uuid.New(), Type: params.Type, Name: params.Name}, false, nil
+}
+
+func TestCreateRecord_MetadataDryRunIsHonored(t *testing.T) {
+	svc := &stubRecordService{}
+	h := NewRecord(svc, &stubContextManager{userID: uuid.New()}, logger.NewNoop())
+
+	// The dry_run flag set only on the shared metadata message must not be
+	// overwritten by the unset request-level flag.
+	resp, err := h.CreateRecord(context.Background(), &pb.CreateRecordRequest{
+		Metadata: &pb.RecordMetadata{
+			Type:         pb.RecordType_RECORD_TYPE_NOTE,
+			Name:         "note",
+			EncryptedKey: []byte("key"),
+			Alg:          "aes-256-gcm",
+			DryRun:       true,
+		},
+		EncryptedData: []byte("payload"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc.createParams == nil || !svc.createParams.DryRun {
+		t.Fatal("service did not receive DryRun=true from metadata")
+	}
+	if !resp.GetSuccess() || resp.GetRecord() != nil {
+		t.Errorf("resp = %+v, want success without a record for a dry run", resp)
+	}
+}
+
+func TestCreateRecord_RequestDryRunIsHonored(t *testing.T) {
+	svc := &stubRecordService{}
+	h := NewRecord(svc, &stubContextManager{userID: uuid.New()}, logger.NewNoop())
+
+	_, err := h.CreateRecord(context.Background(), &pb.CreateRecordRequest{
+		Metadata: &pb.RecordMetadata{
+			Type:         pb.RecordType_RECORD_TYPE_NOTE,
+			Name:         "note",
+			EncryptedKey: []byte("key"),
+			Alg:          "aes-256-gcm",
+		},
+		EncryptedData: []byte("payload"),
+		DryRun:        true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc.createParams == nil || !svc.createParams.DryRun {
+		t.Fatal("service did not receive DryRun=true from the request flag")
+	}
+}
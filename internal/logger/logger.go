@@ -0,0 +1,56 @@
+// Package logger wraps slog with the construction conventions used across the
+// server.
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Logger is the application logger.
+type Logger struct {
+	*slog.Logger
+}
+
+// New creates a logger writing to stdout. Level accepts either an slog
+// integer ("0", "4") or a name ("debug", "info", "warn", "error"); format is
+// "text" or "json", defaulting to text for anything else.
+func New(level, format string) *Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	default:
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return &Logger{Logger: slog.New(handler)}
+}
+
+// NewNoop creates a logger that discards everything. Intended for tests.
+func NewNoop() *Logger {
+	handler := slog.NewTextHandler(io.Discard, nil)
+	return &Logger{Logger: slog.New(handler)}
+}
+
+// parseLevel maps a level string to an slog level, defaulting to info.
+func parseLevel(level string) slog.Level {
+	if n, err := strconv.Atoi(level); err == nil {
+		return slog.Level(n)
+	}
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "info":
+		return slog.LevelInfo
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
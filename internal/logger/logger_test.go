@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in   string
+		want slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"INFO", slog.LevelInfo},
+		{"warn", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"-4", slog.LevelDebug},
+		{"0", slog.LevelInfo},
+		{"8", slog.LevelError},
+		{"bogus", slog.LevelInfo},
+		{"", slog.LevelInfo},
+	}
+	for _, tt := range tests {
+		if got := parseLevel(tt.in); got != tt.want {
+			t.Errorf("parseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNew_Formats(t *testing.T) {
+	for _, format := range []string{"text", "json", ""} {
+		if l := New("info", format); l == nil || l.Logger == nil {
+			t.Errorf("New(info, %q) returned nil logger", format)
+		}
+	}
+}
+
+func TestNewNoop(t *testing.T) {
+	l := NewNoop()
+	l.Info("discarded")
+}
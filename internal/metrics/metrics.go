@@ -0,0 +1,132 @@
+// Package metrics collects business-level counters and serves them in the
+// Prometheus text exposition format. The counters are hand-rolled on
+// sync/atomic instead of pulling in the client library: the server only needs
+// monotonic counters with at most one low-cardinality label.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// counter is a single monotonic counter.
+type counter struct {
+	name string
+	help string
+	v    atomic.Int64
+}
+
+// labeledCounter is a monotonic counter split by one label. Callers must keep
+// the label's value set small and bounded; user IDs and the like do not
+// belong here.
+type labeledCounter struct {
+	name  string
+	help  string
+	label string
+	mu    sync.Mutex
+	v     map[string]int64
+}
+
+func (c *labeledCounter) inc(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.v[value]++
+}
+
+// Metrics holds the server's business-level counters. All increment methods
+// are safe on a nil receiver, so callers constructed without metrics need no
+// guards.
+type Metrics struct {
+	idempotentCreates   *counter
+	quotaRejections     *counter
+	rateLimitRejections *labeledCounter
+	authFailures        *labeledCounter
+}
+
+// New creates the counter set.
+func New() *Metrics {
+	return &Metrics{
+		idempotentCreates: &counter{
+			name: "gophkeeper_idempotent_creates_total",
+			help: "Create requests answered by replaying an existing record.",
+		},
+		quotaRejections: &counter{
+			name: "gophkeeper_record_quota_rejections_total",
+			help: "Create requests rejected by the per-user record quota.",
+		},
+		rateLimitRejections: &labeledCounter{
+			name:  "gophkeeper_rate_limit_rejections_total",
+			help:  "Requests rejected by a concurrency or pending-session limit.",
+			label: "limit",
+			v:     map[string]int64{},
+		},
+		authFailures: &labeledCounter{
+			name:  "gophkeeper_auth_failures_total",
+			help:  "Failed authentication attempts by reason.",
+			label: "reason",
+			v:     map[string]int64{},
+		},
+	}
+}
+
+// IncIdempotentCreate counts a create request answered by an idempotent
+// replay.
+func (m *Metrics) IncIdempotentCreate() {
+	if m == nil {
+		return
+	}
+	m.idempotentCreates.v.Add(1)
+}
+
+// IncQuotaRejection counts a create rejected by the per-user record quota.
+func (m *Metrics) IncQuotaRejection() {
+	if m == nil {
+		return
+	}
+	m.quotaRejections.v.Add(1)
+}
+
+// IncRateLimitRejection counts a request rejected by the named limit.
+func (m *Metrics) IncRateLimitRejection(limit string) {
+	if m == nil {
+		return
+	}
+	m.rateLimitRejections.inc(limit)
+}
+
+// IncAuthFailure counts a failed authentication attempt under the given
+// reason.
+func (m *Metrics) IncAuthFailure(reason string) {
+	if m == nil {
+		return
+	}
+	m.authFailures.inc(reason)
+}
+
+// Handler serves the counters in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, c := range []*counter{m.idempotentCreates, m.quotaRejections} {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+			fmt.Fprintf(w, "%s %d\n", c.name, c.v.Load())
+		}
+		for _, c := range []*labeledCounter{m.rateLimitRejections, m.authFailures} {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+			c.mu.Lock()
+			// Sorted label values keep the output stable across scrapes.
+			values := make([]string, 0, len(c.v))
+			for value := range c.v {
+				values = append(values, value)
+			}
+			sort.Strings(values)
+			for _, value := range values {
+				fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, value, c.v[value])
+			}
+			c.mu.Unlock()
+		}
+	})
+}
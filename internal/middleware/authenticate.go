@@ -0,0 +1,199 @@
+// Package middleware contains the gRPC interceptors.
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+// TokenValidator verifies an access token and returns the user it belongs to
+// along with the token's scopes. ValidateWithGrace additionally accepts a
+// token expired no longer than grace ago.
+type TokenValidator interface {
+	Validate(ctx context.Context, accessToken string) (uuid.UUID, []string, error)
+	ValidateWithGrace(ctx context.Context, accessToken string, grace time.Duration) (uuid.UUID, []string, error)
+}
+
+// ContextManager stores the authenticated user ID and the token scopes in
+// the request context and reads them back. The value-based methods are used
+// because context values survive every interceptor ordering, including
+// wrapped stream contexts.
+type ContextManager interface {
+	SetUserIDValue(ctx context.Context, userID uuid.UUID) context.Context
+	SetScopesValue(ctx context.Context, scopes []string) context.Context
+	GetUserIDValue(ctx context.Context) (uuid.UUID, error)
+}
+
+// DefaultSkipPrefixes are the public methods that never require a token: the
+// auth exchange itself, health checks and reflection. The auth methods are
+// listed individually because the Auth service also carries authenticated
+// methods like RevokeAllSessions.
+var DefaultSkipPrefixes = []string{
+	"/api.Auth/GetKDFPolicy",
+	"/api.Auth/GetRegParams",
+	"/api.Auth/CompleteReg",
+	"/api.Auth/GetLoginParams",
+	"/api.Auth/CompleteLogin",
+	"/api.Auth/Refresh",
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// DefaultMetadataKey and DefaultScheme are the standard bearer-token header
+// and scheme the interceptor falls back to.
+const (
+	DefaultMetadataKey = "authorization"
+	DefaultScheme      = "Bearer"
+)
+
+// Authenticate is the interceptor enforcing bearer-token authentication on
+// record methods.
+type Authenticate struct {
+	tokens         TokenValidator
+	contextManager ContextManager
+	skipPrefixes   []string
+	metadataKey    string
+	scheme         string
+	streamGrace    time.Duration
+	logger         *logger.Logger
+}
+
+// NewAuthenticate creates the authentication interceptor. Methods whose full
+// name starts with one of skipPrefixes bypass authentication; nil falls back
+// to DefaultSkipPrefixes. The token is read from the metadataKey header
+// (empty falls back to DefaultMetadataKey) with an optional scheme prefix; an
+// empty scheme treats the whole header value as the token, for gateways that
+// strip or rename the authorization header. streamGrace loosens token-expiry
+// checking on streaming methods only: streams validate the token once at
+// start, and a token expired no longer than streamGrace ago is still
+// accepted so a long upload started near expiry is not refused outright.
+// Zero applies the same strict check as unary methods.
+func NewAuthenticate(tokens TokenValidator, contextManager ContextManager, skipPrefixes []string, metadataKey, scheme string, streamGrace time.Duration, l *logger.Logger) *Authenticate {
+	if skipPrefixes == nil {
+		skipPrefixes = DefaultSkipPrefixes
+	}
+	if metadataKey == "" {
+		metadataKey = DefaultMetadataKey
+	}
+	return &Authenticate{
+		tokens:         tokens,
+		contextManager: contextManager,
+		skipPrefixes:   skipPrefixes,
+		metadataKey:    metadataKey,
+		scheme:         scheme,
+		streamGrace:    streamGrace,
+		logger:         l,
+	}
+}
+
+// authSkip reports whether the method is public and must not require a token.
+func (a *Authenticate) authSkip(fullMethod string) bool {
+	for _, prefix := range a.skipPrefixes {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthFunc validates the bearer token from incoming metadata and returns a
+// context carrying the authenticated user ID plus the token scopes.
+func (a *Authenticate) AuthFunc(ctx context.Context) (context.Context, []string, error) {
+	return a.authFunc(ctx, 0)
+}
+
+// authFunc is AuthFunc with an expiry grace, used by the stream interceptor.
+func (a *Authenticate) authFunc(ctx context.Context, grace time.Duration) (context.Context, []string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	values := md.Get(a.metadataKey)
+	if len(values) == 0 {
+		return nil, nil, status.Error(codes.Unauthenticated, "missing authorization header")
+	}
+	// The scheme prefix is trimmed only when present, so simple clients may
+	// send the bare token.
+	token := values[0]
+	if a.scheme != "" {
+		token = strings.TrimPrefix(token, a.scheme+" ")
+	}
+
+	userID, scopes, err := a.tokens.ValidateWithGrace(ctx, token, grace)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	authCtx := a.contextManager.SetUserIDValue(ctx, userID)
+	// Scopes travel alongside the user ID so handlers can gate individual
+	// request fields that the per-method scope check cannot express.
+	authCtx = a.contextManager.SetScopesValue(authCtx, scopes)
+	// Read the user back immediately: a manager that silently drops the user
+	// would otherwise make every handler fail Unauthenticated despite a valid
+	// token, which points debugging at the wrong layer.
+	if got, err := a.contextManager.GetUserIDValue(authCtx); err != nil || got != userID {
+		a.logger.Error("context manager did not store the authenticated user", "error", err)
+		return nil, nil, status.Error(codes.Internal, "authentication context misconfigured")
+	}
+	return authCtx, scopes, nil
+}
+
+// checkScopes enforces the scope the method requires, logging legacy unscoped
+// tokens as deprecated. The legacy full-access treatment never extends to the
+// admin methods.
+func (a *Authenticate) checkScopes(fullMethod string, scopes []string) error {
+	if required := requiredScope(fullMethod); required != "" && required != ScopeAdmin && len(scopes) == 0 {
+		a.logger.Warn("unscoped legacy token used, treating as full access", "method", fullMethod)
+		return nil
+	}
+	return RequireScope(fullMethod, scopes)
+}
+
+// Unary is the unary server interceptor enforcing authentication.
+func (a *Authenticate) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if a.authSkip(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, scopes, err := a.AuthFunc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkScopes(info.FullMethod, scopes); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream is the stream server interceptor enforcing authentication.
+func (a *Authenticate) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if a.authSkip(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, scopes, err := a.authFunc(ss.Context(), a.streamGrace)
+	if err != nil {
+		return err
+	}
+	if err := a.checkScopes(info.FullMethod, scopes); err != nil {
+		return err
+	}
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+}
+
+// wrappedStream overrides the stream context with the authenticated one.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the authenticated context.
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
@@ -0,0 +1,293 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	appContext "github.com/dtroode/gophkeeper-server/internal/context"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+type mockValidator struct {
+	userID uuid.UUID
+	scopes []string
+	err    error
+	// lastGrace records the grace the interceptor asked for.
+	lastGrace time.Duration
+}
+
+func (m *mockValidator) Validate(ctx context.Context, accessToken string) (uuid.UUID, []string, error) {
+	return m.ValidateWithGrace(ctx, accessToken, 0)
+}
+
+func (m *mockValidator) ValidateWithGrace(_ context.Context, _ string, grace time.Duration) (uuid.UUID, []string, error) {
+	m.lastGrace = grace
+	return m.userID, m.scopes, m.err
+}
+
+// mockContextManager stores the user ID in a context key unless broken, which
+// simulates a manager returning the original context without the user.
+type mockContextManager struct {
+	broken bool
+}
+
+type mockUserIDKey struct{}
+
+type mockScopesKey struct{}
+
+func (m *mockContextManager) SetUserIDValue(ctx context.Context, userID uuid.UUID) context.Context {
+	if m.broken {
+		return ctx
+	}
+	return context.WithValue(ctx, mockUserIDKey{}, userID)
+}
+
+func (m *mockContextManager) SetScopesValue(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, mockScopesKey{}, scopes)
+}
+
+func (m *mockContextManager) GetScopesValue(ctx context.Context) []string {
+	scopes, _ := ctx.Value(mockScopesKey{}).([]string)
+	return scopes
+}
+
+func (m *mockContextManager) GetUserIDValue(ctx context.Context) (uuid.UUID, error) {
+	userID, ok := ctx.Value(mockUserIDKey{}).(uuid.UUID)
+	if !ok {
+		return uuid.Nil, errors.New("user id not found in context")
+	}
+	return userID, nil
+}
+
+func TestAuthSkip(t *testing.T) {
+	a := NewAuthenticate(&mockValidator{}, &mockContextManager{}, nil, "", "Bearer", 0, logger.NewNoop())
+
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"/api.Auth/GetRegParams", true},
+		{"/api.Auth/CompleteLogin", true},
+		{"/grpc.health.v1.Health/Check", true},
+		{"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", true},
+		{"/api.Auth/RevokeAllSessions", false},
+		{"/api.Records/CreateRecord", false},
+		{"/api.Records/ListRecords", false},
+	}
+	for _, tt := range tests {
+		if got := a.authSkip(tt.method); got != tt.want {
+			t.Errorf("authSkip(%q) = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestAuthSkip_CustomPrefixes(t *testing.T) {
+	a := NewAuthenticate(&mockValidator{}, &mockContextManager{}, []string{"/api.Info/"}, "", "Bearer", 0, logger.NewNoop())
+
+	if !a.authSkip("/api.Info/GetVersion") {
+		t.Error("custom prefix not skipped")
+	}
+	if a.authSkip("/api.Auth/GetRegParams") {
+		t.Error("default prefix skipped despite custom list")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		scopes  []string
+		wantErr bool
+	}{
+		{"read scope on read method", "/api.Records/ListRecords", []string{ScopeRecordsRead}, false},
+		{"read scope on write method", "/api.Records/CreateRecord", []string{ScopeRecordsRead}, true},
+		{"write scope on write method", "/api.Records/CreateRecord", []string{ScopeRecordsWrite}, false},
+		{"write scope implies read", "/api.Records/GetRecord", []string{ScopeRecordsWrite}, false},
+		{"legacy unscoped token", "/api.Records/CreateRecord", nil, false},
+		{"non-record method", "/api.Auth/GetRegParams", []string{ScopeRecordsRead}, false},
+		{"admin scope on admin method", "/api.Auth/CheckEmailRegistered", []string{ScopeAdmin}, false},
+		{"record scopes do not grant admin", "/api.Auth/CheckEmailRegistered", []string{ScopeRecordsRead, ScopeRecordsWrite}, true},
+		{"legacy unscoped token denied admin", "/api.Auth/CheckEmailRegistered", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RequireScope(tt.method, tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RequireScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthFunc_VerifiesUserStoredInContext(t *testing.T) {
+	userID := uuid.New()
+	md := metadata.Pairs("authorization", "Bearer token")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	a := NewAuthenticate(&mockValidator{userID: userID}, &mockContextManager{}, nil, "", "Bearer", 0, logger.NewNoop())
+	authCtx, _, err := a.AuthFunc(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := (&mockContextManager{}).GetUserIDValue(authCtx); err != nil || got != userID {
+		t.Errorf("context user = %v (%v), want %s", got, err, userID)
+	}
+
+	a = NewAuthenticate(&mockValidator{userID: userID}, &mockContextManager{broken: true}, nil, "", "Bearer", 0, logger.NewNoop())
+	_, _, err = a.AuthFunc(ctx)
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("err = %v, want Internal when the manager drops the user", err)
+	}
+}
+
+func TestAuthFunc_StoresScopesInContext(t *testing.T) {
+	userID := uuid.New()
+	md := metadata.Pairs("authorization", "Bearer token")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	cm := &mockContextManager{}
+
+	a := NewAuthenticate(&mockValidator{userID: userID, scopes: []string{ScopeRecordsRead}}, cm, nil, "", "Bearer", 0, logger.NewNoop())
+	authCtx, _, err := a.AuthFunc(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scopes := cm.GetScopesValue(authCtx)
+	if len(scopes) != 1 || scopes[0] != ScopeRecordsRead {
+		t.Errorf("context scopes = %v, want [%s]", scopes, ScopeRecordsRead)
+	}
+}
+
+func TestAuthFunc_MetadataKeyAndScheme(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name        string
+		metadataKey string
+		scheme      string
+		header      string
+		value       string
+	}{
+		{"default bearer", "", "Bearer", "authorization", "Bearer token"},
+		{"bare token with scheme configured", "", "Bearer", "authorization", "token"},
+		{"custom key", "x-gophkeeper-token", "Bearer", "x-gophkeeper-token", "Bearer token"},
+		{"empty scheme", "", "", "authorization", "token"},
+		{"custom key empty scheme", "x-gophkeeper-token", "", "x-gophkeeper-token", "token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthenticate(&mockValidator{userID: userID}, &mockContextManager{}, nil, tt.metadataKey, tt.scheme, 0, logger.NewNoop())
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.header, tt.value))
+
+			authCtx, _, err := a.AuthFunc(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, err := (&mockContextManager{}).GetUserIDValue(authCtx); err != nil || got != userID {
+				t.Errorf("context user = %v (%v), want %s", got, err, userID)
+			}
+		})
+	}
+}
+
+func TestAuthFunc_MissingCustomHeader(t *testing.T) {
+	a := NewAuthenticate(&mockValidator{}, &mockContextManager{}, nil, "x-gophkeeper-token", "Bearer", 0, logger.NewNoop())
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+
+	_, _, err := a.AuthFunc(ctx)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("err = %v, want Unauthenticated when the configured header is absent", err)
+	}
+}
+
+// stubServerStream provides just the context the interceptor reads.
+type stubServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stubServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStream_AppliesTokenGrace(t *testing.T) {
+	userID := uuid.New()
+	validator := &mockValidator{userID: userID}
+	a := NewAuthenticate(validator, &mockContextManager{}, nil, "", "Bearer", 2*time.Minute, logger.NewNoop())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+	handler := func(_ any, _ grpc.ServerStream) error { return nil }
+	err := a.Stream(nil, &stubServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/api.Records/CreateRecordStream"}, handler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if validator.lastGrace != 2*time.Minute {
+		t.Errorf("stream validated with grace %s, want %s", validator.lastGrace, 2*time.Minute)
+	}
+
+	_, _, err = a.AuthFunc(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if validator.lastGrace != 0 {
+		t.Errorf("unary validated with grace %s, want 0", validator.lastGrace)
+	}
+}
+
+func TestUnary_UserIDSurvivesMetadataRebuild(t *testing.T) {
+	userID := uuid.New()
+	cm := appContext.New()
+	a := NewAuthenticate(&mockValidator{userID: userID}, cm, nil, "", "Bearer", 0, logger.NewNoop())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+	// The handler sees the context after an interceptor that rebuilds incoming
+	// metadata from scratch, which drops anything metadata-borne.
+	handler := func(ctx context.Context, _ any) (any, error) {
+		ctx = metadata.NewIncomingContext(ctx, metadata.New(nil))
+		got, err := cm.GetUserIDValue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if got != userID {
+			t.Errorf("handler user = %s, want %s", got, userID)
+		}
+		return nil, nil
+	}
+	if _, err := a.Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.Records/GetRecord"}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStream_UserIDSurvivesInterceptorChain(t *testing.T) {
+	userID := uuid.New()
+	cm := appContext.New()
+	a := NewAuthenticate(&mockValidator{userID: userID}, cm, nil, "", "Bearer", 0, logger.NewNoop())
+	limit := NewStreamLimit(cm, 1, logger.NewNoop())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+	info := &grpc.StreamServerInfo{FullMethod: "/api.Records/CreateRecordStream"}
+	handler := func(_ any, ss grpc.ServerStream) error {
+		got, err := cm.GetUserIDValue(ss.Context())
+		if err != nil {
+			return err
+		}
+		if got != userID {
+			t.Errorf("handler user = %s, want %s", got, userID)
+		}
+		return nil
+	}
+	// Authenticate wraps the stream; StreamLimit reads the user from the
+	// wrapped context, as in the real chain.
+	err := a.Stream(nil, &stubServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		return limit.Stream(srv, ss, info, handler)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
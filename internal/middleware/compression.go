@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	// Registering the gzip encoding lets the server decode compressed
+	// requests and encode responses, regardless of the default below.
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// payloadStreamMethods are exempt from default compression: their payloads
+// are ciphertext with near-random bytes, so gzip burns CPU without shrinking
+// anything.
+var payloadStreamMethods = []string{
+	"/api.Records/GetRecordStream",
+	"/api.Records/CreateRecordStream",
+	"/api.Records/ExportRecords",
+}
+
+// Compression defaults outbound gzip compression on for clients that
+// advertise support for it. Compressed requests are always accepted — the
+// gzip encoding import above takes care of that — and clients still
+// negotiate per call: one that does not list gzip in its accepted encodings
+// gets plain responses. Disabled, the interceptor is a passthrough and
+// compression remains purely client-driven.
+type Compression struct {
+	enabled bool
+}
+
+// NewCompression creates the compression interceptor; enabled false keeps it
+// a passthrough.
+func NewCompression(enabled bool) *Compression {
+	return &Compression{enabled: enabled}
+}
+
+// Unary defaults compression on for unary responses.
+func (c *Compression) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if c.enabled && clientAcceptsGzip(ctx) {
+		// Failure means the first message is already out; nothing to do.
+		_ = grpc.SetSendCompressor(ctx, gzip.Name)
+	}
+	return handler(ctx, req)
+}
+
+// Stream defaults compression on for streaming responses, except on the
+// payload streams.
+func (c *Compression) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if c.enabled && !isPayloadStream(info.FullMethod) && clientAcceptsGzip(ss.Context()) {
+		_ = grpc.SetSendCompressor(ss.Context(), gzip.Name)
+	}
+	return handler(srv, ss)
+}
+
+// isPayloadStream reports whether the method streams encrypted payloads.
+func isPayloadStream(fullMethod string) bool {
+	for _, method := range payloadStreamMethods {
+		if strings.HasPrefix(fullMethod, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAcceptsGzip reports whether the client listed gzip among its accepted
+// encodings.
+func clientAcceptsGzip(ctx context.Context) bool {
+	names, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if name == gzip.Name {
+			return true
+		}
+	}
+	return false
+}
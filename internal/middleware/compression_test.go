@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestCompression_ExemptsPayloadStreams(t *testing.T) {
+	for method, want := range map[string]bool{
+		"/api.Records/GetRecordStream":    true,
+		"/api.Records/CreateRecordStream": true,
+		"/api.Records/ExportRecords":      true,
+		"/api.Records/ListRecords":        false,
+		"/api.Auth/CompleteLogin":         false,
+	} {
+		if got := isPayloadStream(method); got != want {
+			t.Errorf("isPayloadStream(%q) = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestCompression_PassthroughOutsideServerContext(t *testing.T) {
+	// Outside a real gRPC server context the client's accepted encodings are
+	// unknown, so the interceptor must fall back to uncompressed passthrough
+	// rather than erroring.
+	c := NewCompression(true)
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Records/ListRecords"}
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return "ok", nil
+	}
+	if _, err := c.Unary(context.Background(), nil, info, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("handler not invoked")
+	}
+}
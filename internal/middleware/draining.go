@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// drainPollInterval is how often Drain re-checks the in-flight counter.
+const drainPollInterval = 50 * time.Millisecond
+
+// Draining counts in-flight requests so shutdown can wait for them to finish
+// before stopping the server.
+type Draining struct {
+	active atomic.Int64
+}
+
+// NewDraining creates the draining interceptor.
+func NewDraining() *Draining {
+	return &Draining{}
+}
+
+// Active returns the number of requests currently being handled.
+func (d *Draining) Active() int64 {
+	return d.active.Load()
+}
+
+// Unary is the unary server interceptor tracking in-flight requests.
+func (d *Draining) Unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	d.active.Add(1)
+	defer d.active.Add(-1)
+	return handler(ctx, req)
+}
+
+// Stream is the stream server interceptor tracking in-flight streams.
+func (d *Draining) Stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	d.active.Add(1)
+	defer d.active.Add(-1)
+	return handler(srv, ss)
+}
+
+// Drain blocks until no requests are in flight or the timeout elapses,
+// reporting whether the server fully drained. Callers flip readiness to
+// NOT_SERVING before draining so load balancers stop routing new requests.
+func (d *Draining) Drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for d.active.Load() > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+	return true
+}
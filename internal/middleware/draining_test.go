@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestDraining_CountsInFlightRequests(t *testing.T) {
+	d := NewDraining()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		_, _ = d.Unary(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+	}()
+
+	<-started
+	if got := d.Active(); got != 1 {
+		t.Errorf("active = %d, want 1", got)
+	}
+	if d.Drain(10 * time.Millisecond) {
+		t.Error("Drain reported drained with a request in flight")
+	}
+
+	close(release)
+	if !d.Drain(time.Second) {
+		t.Errorf("Drain did not observe completion, active = %d", d.Active())
+	}
+}
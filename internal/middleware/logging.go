@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+// Logging is the interceptor logging every RPC with its duration and status.
+// At high request rates successful calls can be sampled down; failed and slow
+// calls are always logged, so nothing actionable is lost to sampling.
+type Logging struct {
+	logger *logger.Logger
+	// sampleRate logs one in sampleRate successful calls; zero or one logs
+	// every call. slowThreshold exempts calls that took at least that long
+	// from sampling; zero disables the slow-call exemption.
+	sampleRate    int
+	slowThreshold time.Duration
+	counter       atomic.Uint64
+}
+
+// NewLogging creates the logging interceptor.
+func NewLogging(l *logger.Logger, sampleRate int, slowThreshold time.Duration) *Logging {
+	return &Logging{logger: l, sampleRate: sampleRate, slowThreshold: slowThreshold}
+}
+
+// shouldLog decides whether the completed call is logged: failures and slow
+// calls always, successes one in sampleRate. The counter is shared across
+// methods — the sample is of overall traffic, not per method.
+func (m *Logging) shouldLog(code codes.Code, duration time.Duration) bool {
+	if code != codes.OK {
+		return true
+	}
+	if m.slowThreshold > 0 && duration >= m.slowThreshold {
+		return true
+	}
+	if m.sampleRate <= 1 {
+		return true
+	}
+	return m.counter.Add(1)%uint64(m.sampleRate) == 0
+}
+
+// HandleGRPC is the unary server interceptor logging the call.
+func (m *Logging) HandleGRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	duration := time.Since(start)
+
+	code := status.Code(err)
+	if m.shouldLog(code, duration) {
+		m.logger.Info("handled rpc",
+			"method", info.FullMethod,
+			"duration", duration,
+			"code", code.String(),
+		)
+	}
+	return resp, err
+}
+
+// HandleGRPCStream is the stream server interceptor logging the call.
+func (m *Logging) HandleGRPCStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	duration := time.Since(start)
+
+	code := status.Code(err)
+	if m.shouldLog(code, duration) {
+		m.logger.Info("handled stream",
+			"method", info.FullMethod,
+			"duration", duration,
+			"code", code.String(),
+		)
+	}
+	return err
+}
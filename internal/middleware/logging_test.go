@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+func TestShouldLog_SamplesSuccesses(t *testing.T) {
+	m := NewLogging(logger.NewNoop(), 10, 0)
+	logged := 0
+	for i := 0; i < 100; i++ {
+		if m.shouldLog(codes.OK, time.Millisecond) {
+			logged++
+		}
+	}
+	if logged != 10 {
+		t.Errorf("logged = %d of 100, want 10 at rate 10", logged)
+	}
+}
+
+func TestShouldLog_AlwaysLogsErrorsAndSlowCalls(t *testing.T) {
+	m := NewLogging(logger.NewNoop(), 1000, time.Second)
+	for i := 0; i < 50; i++ {
+		if !m.shouldLog(codes.Internal, time.Millisecond) {
+			t.Fatal("error call was sampled out")
+		}
+		if !m.shouldLog(codes.OK, 2*time.Second) {
+			t.Fatal("slow call was sampled out")
+		}
+	}
+}
+
+func TestShouldLog_RateOneLogsEverything(t *testing.T) {
+	m := NewLogging(logger.NewNoop(), 1, 0)
+	for i := 0; i < 10; i++ {
+		if !m.shouldLog(codes.OK, time.Millisecond) {
+			t.Fatal("call was sampled out at rate 1")
+		}
+	}
+}
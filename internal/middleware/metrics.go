@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/metrics"
+)
+
+// Metrics is the interceptor feeding the rejection counters: auth failures by
+// reason, quota rejections and rate-limit rejections. It classifies the
+// errors flowing back through the chain, so it must be chained outside the
+// authentication and stream-limit interceptors to see theirs too.
+type Metrics struct {
+	m *metrics.Metrics
+}
+
+// NewMetrics creates the metrics interceptor.
+func NewMetrics(m *metrics.Metrics) *Metrics {
+	return &Metrics{m: m}
+}
+
+// Unary counts rejections on unary methods.
+func (i *Metrics) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	i.observe(err)
+	return resp, err
+}
+
+// Stream counts rejections on streaming methods.
+func (i *Metrics) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	i.observe(err)
+	return err
+}
+
+// observe classifies an error into the counters. Service errors carry their
+// reason as an ErrorInfo detail; plain statuses from the authentication and
+// stream-limit interceptors are classified by code, with the status message
+// standing in as the auth failure reason.
+func (i *Metrics) observe(err error) {
+	if err == nil {
+		return
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return
+	}
+	reason := statusReason(st)
+	switch {
+	case st.Code() == codes.Unauthenticated:
+		if reason == "" {
+			reason = strings.ReplaceAll(st.Message(), " ", "_")
+		}
+		i.m.IncAuthFailure(reason)
+	case reason == apiErrors.ReasonQuotaExceeded:
+		i.m.IncQuotaRejection()
+	case reason == apiErrors.ReasonTooManyPending:
+		i.m.IncRateLimitRejection("pending_sessions")
+	case st.Code() == codes.ResourceExhausted:
+		// The per-user stream limiter is the only ResourceExhausted source
+		// that carries no reason.
+		i.m.IncRateLimitRejection("concurrent_streams")
+	}
+}
+
+// statusReason extracts the ErrorInfo reason attached by the handlers, or
+// empty when the status carries none.
+func statusReason(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/metrics"
+)
+
+// reasonedError builds a status carrying an ErrorInfo reason, the way the
+// handlers attach it.
+func reasonedError(code codes.Code, reason string) error {
+	st, err := status.New(code, "rejected").WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: apiErrors.Domain})
+	if err != nil {
+		panic(err)
+	}
+	return st.Err()
+}
+
+func TestMetrics_CountsRejections(t *testing.T) {
+	m := metrics.New()
+	i := NewMetrics(m)
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Records/CreateRecord"}
+
+	for _, err := range []error{
+		nil,
+		status.Error(codes.Unauthenticated, "invalid token"),
+		reasonedError(codes.Unauthenticated, "TOKEN_EXPIRED"),
+		reasonedError(codes.ResourceExhausted, apiErrors.ReasonQuotaExceeded),
+		reasonedError(codes.ResourceExhausted, apiErrors.ReasonTooManyPending),
+		status.Error(codes.ResourceExhausted, "too many concurrent streams, limit is 4"),
+		status.Error(codes.NotFound, "record not found"),
+	} {
+		handler := func(ctx context.Context, req any) (any, error) { return nil, err }
+		i.Unary(context.Background(), nil, info, handler)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`gophkeeper_auth_failures_total{reason="invalid_token"} 1`,
+		`gophkeeper_auth_failures_total{reason="TOKEN_EXPIRED"} 1`,
+		"gophkeeper_record_quota_rejections_total 1",
+		`gophkeeper_rate_limit_rejections_total{limit="pending_sessions"} 1`,
+		`gophkeeper_rate_limit_rejections_total{limit="concurrent_streams"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "record not found") || strings.Contains(body, "NotFound") {
+		t.Error("ordinary errors must not be counted")
+	}
+}
+
+func TestMetrics_NilCollectorIsSafe(t *testing.T) {
+	i := NewMetrics(nil)
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if _, err := i.Unary(context.Background(), nil, &grpc.UnaryServerInfo{}, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("err = %v, want the handler error passed through", err)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// userIDMetadataKey is the incoming-metadata key the context manager stores
+// the authenticated user ID under. A client could set the same key on its own
+// request; the sanitizer clears it at the edge so only the server-set value
+// is ever present.
+const userIDMetadataKey = "user_id"
+
+// SanitizeMetadata strips reserved metadata keys from incoming requests
+// before authentication runs. Without it, a spoofed user_id header would be
+// trusted on any method the auth interceptor skips.
+type SanitizeMetadata struct{}
+
+// NewSanitizeMetadata creates the metadata sanitizer.
+func NewSanitizeMetadata() *SanitizeMetadata {
+	return &SanitizeMetadata{}
+}
+
+// Unary is the unary server interceptor stripping reserved metadata.
+func (s *SanitizeMetadata) Unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(stripReservedMetadata(ctx), req)
+}
+
+// Stream is the stream server interceptor stripping reserved metadata.
+func (s *SanitizeMetadata) Stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := stripReservedMetadata(ss.Context())
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+}
+
+// stripReservedMetadata removes client-supplied values under reserved keys
+// from the incoming metadata.
+func stripReservedMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(userIDMetadataKey)) == 0 {
+		return ctx
+	}
+	md = md.Copy()
+	md.Delete(userIDMetadataKey)
+	return metadata.NewIncomingContext(ctx, md)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Scopes understood by the server.
+const (
+	ScopeRecordsRead  = "records:read"
+	ScopeRecordsWrite = "records:write"
+	// ScopeAdmin gates support tooling such as CheckEmailRegistered. Unlike
+	// the record scopes it is never implied by a legacy unscoped token, and
+	// no API path issues it: login may request only the record scopes. Admin
+	// tokens are minted out of band by an operator signing an HS256 JWT with
+	// the server's JWT_SECRET, carrying the operator's user ID in the "sub"
+	// and "uid" claims and ["admin"] in "scopes" — service.Claims is the
+	// authoritative shape. Keep such tokens short-lived; the server stores
+	// no record of them and cannot revoke them.
+	ScopeAdmin = "admin"
+)
+
+// writeMethods lists record methods that mutate state and therefore require
+// records:write.
+var writeMethods = map[string]struct{}{
+	"/api.Records/CreateRecord":         {},
+	"/api.Records/CreateRecordStream":   {},
+	"/api.Records/DeleteRecord":         {},
+	"/api.Records/GetPresignedPutURL":   {},
+	"/api.Records/FinalizeBinaryUpdate": {},
+	"/api.Records/ImportRecords":        {},
+	"/api.Records/TransferRecord":       {},
+	"/api.Records/BatchDeleteRecords":   {},
+	"/api.Records/RekeyRecord":          {},
+	"/api.Records/SetRecordTags":        {},
+	"/api.Records/ChangeRecordType":     {},
+	"/api.Records/SetRecordPinned":      {},
+}
+
+// adminMethods lists methods reserved for support tooling.
+var adminMethods = map[string]struct{}{
+	"/api.Auth/CheckEmailRegistered": {},
+}
+
+// requiredScope returns the scope a method needs, or "" for methods without
+// scope requirements.
+func requiredScope(fullMethod string) string {
+	if _, ok := adminMethods[fullMethod]; ok {
+		return ScopeAdmin
+	}
+	if !strings.HasPrefix(fullMethod, "/api.Records/") {
+		return ""
+	}
+	if _, ok := writeMethods[fullMethod]; ok {
+		return ScopeRecordsWrite
+	}
+	return ScopeRecordsRead
+}
+
+// RequireScope checks that the token scopes allow calling the method. Write
+// scope implies read. An empty scope list is a legacy full-access token and is
+// allowed on the record methods — callers should log its use as deprecated —
+// but never grants admin.
+func RequireScope(fullMethod string, scopes []string) error {
+	required := requiredScope(fullMethod)
+	if required == "" {
+		return nil
+	}
+	if required == ScopeAdmin {
+		for _, scope := range scopes {
+			if scope == ScopeAdmin {
+				return nil
+			}
+		}
+		return status.Errorf(codes.PermissionDenied, "token lacks required scope %s", required)
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+	for _, scope := range scopes {
+		if scope == required {
+			return nil
+		}
+		if scope == ScopeRecordsWrite && required == ScopeRecordsRead {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "token lacks required scope %s", required)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordMutations is every record RPC that mutates state. A new mutating RPC
+// must be added here alongside its writeMethods entry, or the shared test
+// below fails.
+var recordMutations = []string{
+	"/api.Records/CreateRecord",
+	"/api.Records/CreateRecordStream",
+	"/api.Records/DeleteRecord",
+	"/api.Records/BatchDeleteRecords",
+	"/api.Records/GetPresignedPutURL",
+	"/api.Records/FinalizeBinaryUpdate",
+	"/api.Records/ImportRecords",
+	"/api.Records/TransferRecord",
+	"/api.Records/RekeyRecord",
+	"/api.Records/ChangeRecordType",
+	"/api.Records/SetRecordTags",
+	"/api.Records/SetRecordPinned",
+}
+
+func TestRequireScope_EveryMutationNeedsWrite(t *testing.T) {
+	for _, method := range recordMutations {
+		if got := requiredScope(method); got != ScopeRecordsWrite {
+			t.Errorf("requiredScope(%s) = %q, want %q", method, got, ScopeRecordsWrite)
+		}
+		err := RequireScope(method, []string{ScopeRecordsRead})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("read-only token on %s: code = %v, want PermissionDenied", method, status.Code(err))
+		}
+		if err := RequireScope(method, []string{ScopeRecordsWrite}); err != nil {
+			t.Errorf("write token on %s: %v", method, err)
+		}
+	}
+}
+
+func TestRequireScope_ReadOnlyTokenDeniedWrites(t *testing.T) {
+	err := RequireScope("/api.Records/TransferRecord", []string{ScopeRecordsRead})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("read-only token on TransferRecord: code = %v, want PermissionDenied", status.Code(err))
+	}
+	if err := RequireScope("/api.Records/TransferRecord", []string{ScopeRecordsWrite}); err != nil {
+		t.Fatalf("write token on TransferRecord: %v", err)
+	}
+}
+
+func TestRequireScope_ReadOnlyTokenDeniedBatchDelete(t *testing.T) {
+	err := RequireScope("/api.Records/BatchDeleteRecords", []string{ScopeRecordsRead})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("read-only token on BatchDeleteRecords: code = %v, want PermissionDenied", status.Code(err))
+	}
+}
+
+func TestRequireScope_ReadMethodsAllowReadOnly(t *testing.T) {
+	for _, method := range []string{
+		"/api.Records/GetRecord",
+		"/api.Records/ListRecords",
+		"/api.Records/ExportRecords",
+		"/api.Records/GetStorageUsage",
+	} {
+		if err := RequireScope(method, []string{ScopeRecordsRead}); err != nil {
+			t.Errorf("read token on %s: %v", method, err)
+		}
+	}
+}
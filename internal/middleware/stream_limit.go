@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+// StreamLimit caps concurrent streams per authenticated user. Each open
+// stream holds goroutines and an object-store session, so without a cap a
+// single misbehaving or compromised client can exhaust the server. It must
+// be chained after Authenticate so the stream context carries the user.
+type StreamLimit struct {
+	contextManager ContextManager
+	max            int
+	mu             sync.Mutex
+	active         map[uuid.UUID]int
+	logger         *logger.Logger
+}
+
+// NewStreamLimit creates the per-user stream limiter. max is the number of
+// concurrent streams one user may hold; zero disables the limit.
+func NewStreamLimit(contextManager ContextManager, max int, l *logger.Logger) *StreamLimit {
+	return &StreamLimit{
+		contextManager: contextManager,
+		max:            max,
+		active:         map[uuid.UUID]int{},
+		logger:         l,
+	}
+}
+
+// Stream is the stream server interceptor enforcing the per-user cap. The
+// release is deferred so the slot is returned on normal completion,
+// cancellation and panics alike.
+func (s *StreamLimit) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if s.max <= 0 {
+		return handler(srv, ss)
+	}
+	userID, err := s.contextManager.GetUserIDValue(ss.Context())
+	if err != nil {
+		// Public streams carry no user and are not counted.
+		return handler(srv, ss)
+	}
+	if !s.acquire(userID) {
+		s.logger.Warn("concurrent stream limit reached", "user_id", userID, "method", info.FullMethod)
+		return status.Errorf(codes.ResourceExhausted, "too many concurrent streams, limit is %d", s.max)
+	}
+	defer s.release(userID)
+	return handler(srv, ss)
+}
+
+// acquire takes a stream slot for the user, reporting false when the cap is
+// reached.
+func (s *StreamLimit) acquire(userID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[userID] >= s.max {
+		return false
+	}
+	s.active[userID]++
+	return true
+}
+
+// release returns the user's stream slot, dropping the map entry at zero so
+// the map does not grow with every user ever seen.
+func (s *StreamLimit) release(userID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[userID]--
+	if s.active[userID] <= 0 {
+		delete(s.active, userID)
+	}
+}
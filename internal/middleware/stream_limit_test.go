@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+func TestStreamLimit_RejectsBeyondCapAndReleases(t *testing.T) {
+	cm := &mockContextManager{}
+	limit := NewStreamLimit(cm, 2, logger.NewNoop())
+	userID := uuid.New()
+	ctx := cm.SetUserIDValue(context.Background(), userID)
+	info := &grpc.StreamServerInfo{FullMethod: "/api.Records/CreateRecordStream"}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	blocking := func(srv any, ss grpc.ServerStream) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	for i := 0; i < 2; i++ {
+		go func() {
+			_ = limit.Stream(nil, &stubServerStream{ctx: ctx}, info, blocking)
+			done <- struct{}{}
+		}()
+		<-started
+	}
+
+	err := limit.Stream(nil, &stubServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		return nil
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted over the cap, got %v", err)
+	}
+
+	// Another user is counted independently.
+	otherCtx := cm.SetUserIDValue(context.Background(), uuid.New())
+	if err := limit.Stream(nil, &stubServerStream{ctx: otherCtx}, info, func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("expected other user's stream to pass, got %v", err)
+	}
+
+	close(release)
+	<-done
+	<-done
+	if err := limit.Stream(nil, &stubServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("expected stream to pass after slots released, got %v", err)
+	}
+}
+
+func TestStreamLimit_ReleasesSlotOnPanic(t *testing.T) {
+	cm := &mockContextManager{}
+	limit := NewStreamLimit(cm, 1, logger.NewNoop())
+	ctx := cm.SetUserIDValue(context.Background(), uuid.New())
+	info := &grpc.StreamServerInfo{FullMethod: "/api.Records/GetRecordStream"}
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = limit.Stream(nil, &stubServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+			panic("handler blew up")
+		})
+	}()
+
+	if err := limit.Stream(nil, &stubServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("expected slot released after panic, got %v", err)
+	}
+}
+
+func TestStreamLimit_SkipsUnauthenticatedAndDisabled(t *testing.T) {
+	cm := &mockContextManager{}
+	info := &grpc.StreamServerInfo{FullMethod: "/api.Records/CreateRecordStream"}
+	ok := func(srv any, ss grpc.ServerStream) error { return nil }
+
+	// No user in context: public streams are not counted.
+	limit := NewStreamLimit(cm, 1, logger.NewNoop())
+	if err := limit.Stream(nil, &stubServerStream{ctx: context.Background()}, info, ok); err != nil {
+		t.Fatalf("expected unauthenticated stream to pass, got %v", err)
+	}
+
+	// Zero cap disables the limit entirely.
+	disabled := NewStreamLimit(cm, 0, logger.NewNoop())
+	ctx := cm.SetUserIDValue(context.Background(), uuid.New())
+	for i := 0; i < 3; i++ {
+		if err := disabled.Stream(nil, &stubServerStream{ctx: ctx}, info, ok); err != nil {
+			t.Fatalf("expected disabled limiter to pass, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validatedRequest fails its own validation when invalid is set.
+type validatedRequest struct {
+	invalid bool
+}
+
+func (r *validatedRequest) Validate() error {
+	if r.invalid {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+// plainRequest carries no validation at all.
+type plainRequest struct{}
+
+func TestValidate_Unary(t *testing.T) {
+	v := NewValidate()
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Records/GetRecord"}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	if _, err := v.Unary(context.Background(), &validatedRequest{}, info, handler); err != nil {
+		t.Fatalf("valid request: unexpected error: %v", err)
+	}
+	if _, err := v.Unary(context.Background(), &plainRequest{}, info, handler); err != nil {
+		t.Fatalf("non-validating request: unexpected error: %v", err)
+	}
+
+	_, err := v.Unary(context.Background(), &validatedRequest{invalid: true}, info, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	if status.Convert(err).Message() != "id is required" {
+		t.Errorf("message = %q, want the validation failure", status.Convert(err).Message())
+	}
+}
+
+// recvStream yields predefined messages by copying them into the target.
+type recvStream struct {
+	grpc.ServerStream
+	messages []*validatedRequest
+}
+
+func (s *recvStream) Context() context.Context { return context.Background() }
+
+func (s *recvStream) RecvMsg(m any) error {
+	if len(s.messages) == 0 {
+		return io.EOF
+	}
+	*m.(*validatedRequest) = *s.messages[0]
+	s.messages = s.messages[1:]
+	return nil
+}
+
+func TestValidate_StreamChecksEachMessage(t *testing.T) {
+	v := NewValidate()
+	info := &grpc.StreamServerInfo{FullMethod: "/api.Records/CreateRecordStream"}
+	ss := &recvStream{messages: []*validatedRequest{{}, {invalid: true}}}
+
+	var recvErr error
+	handler := func(srv any, stream grpc.ServerStream) error {
+		for {
+			msg := &validatedRequest{}
+			if recvErr = stream.RecvMsg(msg); recvErr != nil {
+				return recvErr
+			}
+		}
+	}
+	err := v.Stream(nil, ss, info, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument from the second message", err)
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+import "context"
+
+// primaryReadsKey marks a context whose read queries must hit the primary
+// database.
+type primaryReadsKey struct{}
+
+// WithPrimaryReads marks the context so stores route read-only queries to the
+// primary database even when a read replica is configured. Use it around
+// read-for-write sequences, where replication lag would make a just-written
+// row invisible.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// PrimaryReadsRequested reports whether WithPrimaryReads marked the context.
+func PrimaryReadsRequested(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return forced
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecordEventType classifies an outbox event.
+type RecordEventType string
+
+// Outbox event types.
+const (
+	RecordEventCreated RecordEventType = "created"
+	RecordEventUpdated RecordEventType = "updated"
+	RecordEventDeleted RecordEventType = "deleted"
+)
+
+// RecordEvent is an outbox row describing a change to a record. Events are
+// written in the same transaction as the change itself, so a client tailing
+// the outbox never observes a change without its event. Seq is a monotonically
+// increasing cursor a client uses to resume a watch after reconnecting.
+type RecordEvent struct {
+	Seq        int64
+	UserID     uuid.UUID
+	RecordID   uuid.UUID
+	EventType  RecordEventType
+	OccurredAt time.Time
+}
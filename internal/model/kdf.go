@@ -0,0 +1,28 @@
+package model
+
+// KDFParams describes the Argon2id parameters a client must use when deriving
+// its master key. The server issues them during registration and stores a copy
+// per user.
+type KDFParams struct {
+	Alg    string `json:"alg"`
+	Time   uint32 `json:"time"`
+	MemKiB uint32 `json:"mem_kib"`
+	Par    uint8  `json:"par"`
+}
+
+// WeakerThan reports whether any of the parameters is below the given policy.
+// It is used to hint clients that their stored credentials should be
+// re-derived after the server policy was strengthened.
+func (p KDFParams) WeakerThan(policy KDFParams) bool {
+	return p.Time < policy.Time || p.MemKiB < policy.MemKiB || p.Par < policy.Par
+}
+
+// NewKDFParams builds Argon2id parameters from the server configuration.
+func NewKDFParams(time, memKiB uint32, par uint8) KDFParams {
+	return KDFParams{
+		Alg:    "argon2id",
+		Time:   time,
+		MemKiB: memKiB,
+		Par:    par,
+	}
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecordType is the kind of private data stored in a record.
+type RecordType string
+
+// Supported record types.
+const (
+	RecordTypeLogin  RecordType = "login"
+	RecordTypeNote   RecordType = "note"
+	RecordTypeCard   RecordType = "card"
+	RecordTypeBinary RecordType = "binary"
+)
+
+// Valid reports whether t is one of the supported record types.
+func (t RecordType) Valid() bool {
+	switch t {
+	case RecordTypeLogin, RecordTypeNote, RecordTypeCard, RecordTypeBinary:
+		return true
+	}
+	return false
+}
+
+// Record is a single encrypted user record. Non-binary records keep the
+// ciphertext inline in EncryptedData; binary records store it in the object
+// store under S3Key.
+type Record struct {
+	ID            uuid.UUID
+	OwnerID       uuid.UUID
+	RequestID     *uuid.UUID
+	Type          RecordType
+	Name          string
+	Description   string
+	EncryptedData []byte
+	EncryptedKey  []byte
+	Alg           string
+	// Compression names the algorithm the client compressed the plaintext
+	// with before encrypting, e.g. "gzip". The server never inflates; it only
+	// echoes the value back so the client knows how to decode after download.
+	Compression        string
+	S3Key              string
+	EncryptedChunkSize int64
+	// FileSize is the encrypted payload size in bytes, measured at upload.
+	// Zero for records stored before sizes were tracked, which skips the
+	// download truncation check.
+	FileSize int64
+	// ETag is the object store's entity tag for the uploaded payload, captured
+	// at upload time. Clients compare it across syncs to skip re-downloading
+	// unchanged binaries. Empty for inline records and for binaries stored
+	// before tags were tracked.
+	ETag string
+	// Tags are free-form plaintext labels for organizing records; like Name
+	// they are metadata and intentionally not encrypted.
+	Tags []string
+	// Pinned marks a frequently used record; plaintext metadata like Tags.
+	Pinned bool
+	// Version increases on every mutation including soft-delete, giving
+	// clients an optimistic-concurrency token.
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// Tombstone marks a soft-deleted record for delta synchronization.
+type Tombstone struct {
+	ID        uuid.UUID
+	DeletedAt time.Time
+}
+
+// StorageUsage aggregates a user's live records: total stored bytes — inline
+// payloads plus object-backed file sizes — and record counts per type.
+// Soft-deleted records are excluded, consistently with the record quota.
+type StorageUsage struct {
+	TotalBytes  int64
+	CountByType map[RecordType]int
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingSignup is a registration session created by GetRegParams and consumed
+// by CompleteReg.
+type PendingSignup struct {
+	SessionID   uuid.UUID
+	Email       string
+	KDF         []byte
+	ServerNonce []byte
+	ExpiresAt   time.Time
+	Consumed    bool
+}
+
+// PendingLogin is a login session created by GetLoginParams and consumed by
+// CompleteLogin.
+type PendingLogin struct {
+	SessionID   uuid.UUID
+	UserID      uuid.UUID
+	Login       string
+	ClientNonce []byte
+	ServerNonce []byte
+	ExpiresAt   time.Time
+	Consumed    bool
+}
+
+// SessionResult is returned to a client after successful registration or
+// login completion. KDFUpgradeRecommended tells the client its stored KDF
+// parameters are weaker than the current server policy and credentials should
+// be re-derived via ChangeCredentials.
+type SessionResult struct {
+	AccessToken           string
+	RefreshToken          string
+	KDFUpgradeRecommended bool
+}
+
+// RefreshToken is a stored refresh token belonging to a user session. Scopes
+// carry the access limits the pair was issued with so rotation preserves them.
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash []byte
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
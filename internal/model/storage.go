@@ -0,0 +1,29 @@
+package model
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Storage is the object store keeping binary record payloads.
+type Storage interface {
+	// Upload stores the object and returns the backend's ETag for it.
+	Upload(ctx context.Context, key string, data io.Reader, size int64) (string, error)
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// Stat returns the size in bytes of the object stored under the key.
+	Stat(ctx context.Context, key string) (int64, error)
+	// StatETag returns the size and ETag of the object stored under the key.
+	StatETag(ctx context.Context, key string) (int64, string, error)
+	// PresignedPut returns a URL a client can PUT the object's new contents
+	// to directly, valid for the given expiry.
+	PresignedPut(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// List returns up to limit object keys lexically greater than afterKey,
+	// in lexical order. An empty afterKey starts from the beginning.
+	List(ctx context.Context, afterKey string, limit int) ([]string, error)
+	// Copy rewrites an object in place via server-side copy, so it is stored
+	// under the bucket's current encryption settings.
+	Copy(ctx context.Context, key string) error
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecordStore persists record metadata and inline payloads.
+type RecordStore interface {
+	// Create inserts the record, or returns the previously created row when
+	// the request_id was already used. The bool reports whether a fresh row
+	// was inserted, so callers can tell a replay from a first insert.
+	Create(ctx context.Context, record *Record) (*Record, bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Record, error)
+	GetByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*Record, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Record, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Record, error)
+	GetIDsByUserID(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	// CountByUserID returns the number of live records the user owns.
+	CountByUserID(ctx context.Context, userID uuid.UUID) (int, error)
+	// UsageByUserID aggregates the user's live records into a StorageUsage.
+	UsageByUserID(ctx context.Context, userID uuid.UUID) (*StorageUsage, error)
+	// GetByUserIDAndTag returns the user's records carrying the given tag.
+	GetByUserIDAndTag(ctx context.Context, userID uuid.UUID, tag string) ([]*Record, error)
+	// SetTags replaces a record's tags.
+	SetTags(ctx context.Context, id uuid.UUID, tags []string, at time.Time) error
+	// GetPinnedByUserID returns the user's pinned records.
+	GetPinnedByUserID(ctx context.Context, userID uuid.UUID) ([]*Record, error)
+	// SetPinned sets a record's pinned flag.
+	SetPinned(ctx context.Context, id uuid.UUID, pinned bool, at time.Time) error
+	SetType(ctx context.Context, id uuid.UUID, t RecordType, at time.Time) error
+	GetUpdatedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]*Record, error)
+	GetDeletedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]Tombstone, error)
+	// Rekey replaces the encrypted data key and algorithm of a record,
+	// leaving the payload untouched.
+	Rekey(ctx context.Context, id uuid.UUID, encryptedKey []byte, alg string, at time.Time) error
+	// UpdateObjectMeta refreshes a live record's object-derived metadata —
+	// file size and ETag — after its stored object changed out of band,
+	// bumping the version and writing an updated outbox event.
+	UpdateObjectMeta(ctx context.Context, id uuid.UUID, fileSize int64, etag string, at time.Time) error
+	// ReplaceContent overwrites a live record's client-mutable fields — type,
+	// name, description, inline payload, key envelope, compression, tags and
+	// pinned flag — bumping the version and writing an updated outbox event in
+	// the same transaction. Any object backing is cleared: the row becomes
+	// inline-only.
+	ReplaceContent(ctx context.Context, record *Record, at time.Time) error
+	Delete(ctx context.Context, id uuid.UUID, deletedAt time.Time) error
+	// DeleteMany soft-deletes the given records in a single transaction.
+	// Already deleted rows are skipped.
+	DeleteMany(ctx context.Context, ids []uuid.UUID, deletedAt time.Time) error
+	TransferOwner(ctx context.Context, id uuid.UUID, newOwnerID uuid.UUID, at time.Time) error
+	// ListDeletedBefore returns soft-deleted records whose tombstone is older
+	// than the cutoff, up to limit rows.
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*Record, error)
+	// ListDeletedBeforeForUser is ListDeletedBefore restricted to one user's
+	// records.
+	ListDeletedBeforeForUser(ctx context.Context, userID uuid.UUID, before time.Time, limit int) ([]*Record, error)
+	// HardDelete permanently removes a soft-deleted row.
+	HardDelete(ctx context.Context, id uuid.UUID) error
+}
+
+// TombstoneAckStore persists per-device tombstone acknowledgments, which gate
+// purging fully propagated tombstones ahead of the retention cutoff.
+type TombstoneAckStore interface {
+	// Upsert records that the device has applied every tombstone deleted
+	// before ackedBefore. An acknowledgment never moves backwards.
+	Upsert(ctx context.Context, userID uuid.UUID, deviceID string, ackedBefore, at time.Time) error
+	// HighWaterMark returns the earliest acknowledged timestamp across the
+	// user's devices, or the zero time when the user has none.
+	HighWaterMark(ctx context.Context, userID uuid.UUID) (time.Time, error)
+}
+
+// EventStore reads and prunes the record-event outbox.
+type EventStore interface {
+	ListAfter(ctx context.Context, userID uuid.UUID, afterSeq int64, limit int) ([]RecordEvent, error)
+	// DeleteOlderThan removes events that occurred before the cutoff,
+	// returning how many were deleted.
+	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+// UserStore persists user accounts.
+type UserStore interface {
+	Create(ctx context.Context, user *User) (*User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	// List returns up to limit users with IDs greater than afterID, in ID
+	// order, backing maintenance scans that page through the whole table.
+	List(ctx context.Context, afterID uuid.UUID, limit int) ([]*User, error)
+	// UpdateKDF overwrites the stored KDF parameter blob.
+	UpdateKDF(ctx context.Context, id uuid.UUID, kdf []byte) error
+}
+
+// PendingSignupStore persists registration sessions.
+type PendingSignupStore interface {
+	Create(ctx context.Context, signup *PendingSignup) error
+	GetBySessionID(ctx context.Context, sessionID uuid.UUID) (*PendingSignup, error)
+	CountActiveByEmail(ctx context.Context, email string) (int, error)
+	Consume(ctx context.Context, sessionID uuid.UUID) error
+}
+
+// PendingLoginStore persists login sessions.
+type PendingLoginStore interface {
+	Create(ctx context.Context, login *PendingLogin) error
+	GetBySessionID(ctx context.Context, sessionID uuid.UUID) (*PendingLogin, error)
+	// GetActiveByLogin returns the most recent unconsumed, unexpired session
+	// for the login, or nil when there is none.
+	GetActiveByLogin(ctx context.Context, login string) (*PendingLogin, error)
+	CountActiveByLogin(ctx context.Context, login string) (int, error)
+	Consume(ctx context.Context, sessionID uuid.UUID) error
+}
+
+// RefreshTokenStore persists refresh tokens.
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByID(ctx context.Context, id uuid.UUID) (*RefreshToken, error)
+	ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error)
+	Revoke(ctx context.Context, id uuid.UUID, revokedAt time.Time) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error
+}
+
+// CheckpointStore persists resumable progress markers for maintenance jobs.
+type CheckpointStore interface {
+	// Get returns the stored checkpoint for the job, or "" when none exists.
+	Get(ctx context.Context, job string) (string, error)
+	// Set stores the checkpoint for the job, replacing any previous value.
+	Set(ctx context.Context, job, checkpoint string) error
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User is a registered account. Verifier and Salt belong to the SCRAM-style
+// authentication exchange; KDF holds the serialized KDFParams the client used
+// to derive its keys.
+type User struct {
+	ID        uuid.UUID
+	Email     string
+	Verifier  []byte
+	Salt      []byte
+	KDF       []byte
+	CreatedAt time.Time
+}
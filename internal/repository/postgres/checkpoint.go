@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CheckpointRepository persists maintenance-job checkpoints.
+type CheckpointRepository struct {
+	db *Connection
+}
+
+// NewCheckpointRepository creates a checkpoint repository.
+func NewCheckpointRepository(db *Connection) *CheckpointRepository {
+	return &CheckpointRepository{db: db}
+}
+
+// Get returns the stored checkpoint for the job, or "" when none exists.
+func (r *CheckpointRepository) Get(ctx context.Context, job string) (string, error) {
+	var checkpoint string
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT checkpoint FROM job_checkpoints WHERE job = $1`, job,
+	).Scan(&checkpoint)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// Set stores the checkpoint for the job, replacing any previous value.
+func (r *CheckpointRepository) Set(ctx context.Context, job, checkpoint string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO job_checkpoints (job, checkpoint)
+		VALUES ($1, $2)
+		ON CONFLICT (job) DO UPDATE SET checkpoint = EXCLUDED.checkpoint`, job, checkpoint,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert checkpoint: %w", err)
+	}
+	return nil
+}
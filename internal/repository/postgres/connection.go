@@ -0,0 +1,108 @@
+// Package postgres implements the persistence layer on top of pgx.
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// Connection wraps the pgx connection pools shared by the repositories: the
+// primary, and optionally a read replica that read-only queries are routed to.
+type Connection struct {
+	Pool    *pgxpool.Pool
+	replica *pgxpool.Pool
+}
+
+// NewConection opens a connection pool for the given DSN and waits for the
+// database to answer a ping, retrying up to attempts times with the given
+// backoff between tries. This keeps startup robust when Postgres comes up
+// slower than the server (for example under docker-compose).
+func NewConection(ctx context.Context, dsn string, attempts int, backoff time.Duration, l *logger.Logger) (*Connection, error) {
+	pool, err := newPool(ctx, dsn, attempts, backoff, l)
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{Pool: pool}, nil
+}
+
+// ConnectReplica opens a second pool against a read replica. Repositories
+// route read-only queries there via ReadPool; writes stay on the primary.
+func (c *Connection) ConnectReplica(ctx context.Context, dsn string, attempts int, backoff time.Duration, l *logger.Logger) error {
+	pool, err := newPool(ctx, dsn, attempts, backoff, l)
+	if err != nil {
+		return fmt.Errorf("connect replica: %w", err)
+	}
+	c.replica = pool
+	return nil
+}
+
+// ReadPool returns the pool read-only queries should use: the replica when
+// one is configured, unless the context was marked with
+// model.WithPrimaryReads because the caller just wrote and cannot tolerate
+// replication lag.
+func (c *Connection) ReadPool(ctx context.Context) *pgxpool.Pool {
+	if c.replica == nil || model.PrimaryReadsRequested(ctx) {
+		return c.Pool
+	}
+	return c.replica
+}
+
+// newPool dials a pool and waits for the database to answer a ping.
+func newPool(ctx context.Context, dsn string, attempts int, backoff time.Duration, l *logger.Logger) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("create pool: %w", err)
+	}
+
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		err = pool.Ping(ctx)
+		if err == nil {
+			return pool, nil
+		}
+		if attempt >= attempts {
+			break
+		}
+		l.Info("postgres not ready, retrying",
+			"attempt", attempt,
+			"attempts", attempts,
+			"backoff", backoff,
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			pool.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	pool.Close()
+	return nil, fmt.Errorf("ping database: %w", err)
+}
+
+// Ping checks that the primary (and the replica, when configured) answers.
+func (c *Connection) Ping(ctx context.Context) error {
+	if err := c.Pool.Ping(ctx); err != nil {
+		return err
+	}
+	if c.replica != nil {
+		return c.replica.Ping(ctx)
+	}
+	return nil
+}
+
+// Close closes the underlying pools.
+func (c *Connection) Close() {
+	c.Pool.Close()
+	if c.replica != nil {
+		c.replica.Close()
+	}
+}
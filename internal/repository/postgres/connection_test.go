@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+func TestReadPool_Routing(t *testing.T) {
+	primary := new(pgxpool.Pool)
+	replica := new(pgxpool.Pool)
+	ctx := context.Background()
+
+	c := &Connection{Pool: primary}
+	if got := c.ReadPool(ctx); got != primary {
+		t.Error("without a replica reads must use the primary")
+	}
+
+	c.replica = replica
+	if got := c.ReadPool(ctx); got != replica {
+		t.Error("with a replica reads must use it")
+	}
+	if got := c.ReadPool(model.WithPrimaryReads(ctx)); got != primary {
+		t.Error("marked context must force the primary")
+	}
+}
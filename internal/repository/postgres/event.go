@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// EventRepository reads the record-event outbox.
+type EventRepository struct {
+	db *Connection
+}
+
+// NewEventRepository creates an event repository.
+func NewEventRepository(db *Connection) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// ListAfter returns up to limit events of the user with a sequence number
+// greater than afterSeq, oldest first.
+func (r *EventRepository) ListAfter(ctx context.Context, userID uuid.UUID, afterSeq int64, limit int) ([]model.RecordEvent, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT seq, user_id, record_id, event_type, occurred_at
+		FROM record_events
+		WHERE user_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3`, userID, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query record events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.RecordEvent
+	for rows.Next() {
+		var event model.RecordEvent
+		if err := rows.Scan(&event.Seq, &event.UserID, &event.RecordID, &event.EventType, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("scan record event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// DeleteOlderThan removes events that occurred before the cutoff, returning
+// how many rows were deleted.
+func (r *EventRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM record_events
+		WHERE occurred_at < $1`, before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete record events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// insertEvent appends an outbox row inside the caller's transaction so the
+// event commits or rolls back together with the record change.
+func insertEvent(ctx context.Context, tx pgx.Tx, userID, recordID uuid.UUID, eventType model.RecordEventType) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO record_events (user_id, record_id, event_type)
+		VALUES ($1, $2, $3)`, userID, recordID, eventType,
+	)
+	if err != nil {
+		return fmt.Errorf("insert record event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobLock serializes a named periodic job across server replicas using a
+// session-scoped Postgres advisory lock. The lock lives on a dedicated pooled
+// connection pinned for as long as it is held, so it is released automatically
+// if the session dies with the replica.
+type JobLock struct {
+	conn *Connection
+	key  int64
+	held *pgxpool.Conn
+}
+
+// NewJobLock creates a lock for the given job name. The name is hashed to the
+// advisory lock key, so every replica using the same name contends on the
+// same lock.
+func NewJobLock(conn *Connection, name string) *JobLock {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &JobLock{conn: conn, key: int64(h.Sum64())}
+}
+
+// TryAcquire attempts to take the advisory lock without blocking, reporting
+// whether this replica now holds it. Calling it while already holding the
+// lock is a no-op returning true, so job loops can attempt it every cycle.
+func (j *JobLock) TryAcquire(ctx context.Context) (bool, error) {
+	if j.held != nil {
+		return true, nil
+	}
+	conn, err := j.conn.Pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, j.key).Scan(&locked); err != nil {
+		conn.Release()
+		return false, fmt.Errorf("try advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Release()
+		return false, nil
+	}
+	j.held = conn
+	return true, nil
+}
+
+// Release unlocks and returns the pinned connection to the pool. It is safe
+// to call without holding the lock.
+func (j *JobLock) Release(ctx context.Context) error {
+	if j.held == nil {
+		return nil
+	}
+	_, err := j.held.Exec(ctx, `SELECT pg_advisory_unlock($1)`, j.key)
+	j.held.Release()
+	j.held = nil
+	if err != nil {
+		return fmt.Errorf("advisory unlock: %w", err)
+	}
+	return nil
+}
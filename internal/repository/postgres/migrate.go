@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockPollInterval is how often a replica waiting for the migration
+// lock re-checks it.
+const migrationLockPollInterval = 500 * time.Millisecond
+
+// PendingMigrations returns the embedded migrations that have not been
+// applied yet, in apply order. A database without the schema_migrations table
+// reports every migration as pending. It backs the startup dependency checks
+// for deployments that apply migrations out of band.
+func PendingMigrations(ctx context.Context, db *Connection) ([]string, error) {
+	var tableExists bool
+	if err := db.Pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&tableExists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	applied := map[string]bool{}
+	if tableExists {
+		rows, err := db.Pool.Query(ctx, `SELECT version FROM schema_migrations`)
+		if err != nil {
+			return nil, fmt.Errorf("query applied migrations: %w", err)
+		}
+		for rows.Next() {
+			var version string
+			if err := rows.Scan(&version); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan migration version: %w", err)
+			}
+			applied[version] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("read applied migrations: %w", err)
+		}
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir: %w", err)
+	}
+	var pending []string
+	for _, entry := range entries {
+		if !applied[entry.Name()] {
+			pending = append(pending, entry.Name())
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// RunMigrations applies the embedded schema migrations that have not been
+// applied yet, in file-name order. Each migration runs in its own transaction
+// together with the bookkeeping insert, so a failed migration leaves the
+// schema at the previous version. The advisory migration lock serializes
+// concurrent replicas starting at once.
+func RunMigrations(ctx context.Context, db *Connection, l *logger.Logger) error {
+	lock := NewJobLock(db, "schema-migrations")
+	// Wait for the lock rather than skip: a replica must not serve until the
+	// schema is current, and re-checking schema_migrations after another
+	// replica finishes is cheap.
+	for {
+		held, err := lock.TryAcquire(ctx)
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if held {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(migrationLockPollInterval):
+		}
+	}
+	defer lock.Release(context.Background())
+
+	_, err := db.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied := map[string]bool{}
+	rows, err := db.Pool.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("query applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan migration version: %w", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read applied migrations: %w", err)
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("read migrations dir: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if applied[name] {
+			continue
+		}
+		sql, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return fmt.Errorf("read migration %s: %w", name, err)
+		}
+		tx, err := db.Pool.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin migration %s: %w", name, err)
+		}
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("apply migration %s: %w", name, err)
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO schema_migrations (version) VALUES ($1)`, name); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("record migration %s: %w", name, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("commit migration %s: %w", name, err)
+		}
+		l.Info("applied migration", "version", name)
+	}
+	return nil
+}
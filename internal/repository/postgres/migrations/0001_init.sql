@@ -0,0 +1,61 @@
+CREATE TABLE IF NOT EXISTS users (
+    id         UUID PRIMARY KEY,
+    email      TEXT NOT NULL UNIQUE,
+    verifier   BYTEA NOT NULL,
+    salt       BYTEA NOT NULL,
+    kdf        BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS pending_signups (
+    session_id   UUID PRIMARY KEY,
+    email        TEXT NOT NULL,
+    kdf          BYTEA NOT NULL,
+    server_nonce BYTEA NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    consumed     BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS pending_logins (
+    session_id   UUID PRIMARY KEY,
+    user_id      UUID NOT NULL REFERENCES users (id),
+    login        TEXT NOT NULL,
+    client_nonce BYTEA NOT NULL,
+    server_nonce BYTEA NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    consumed     BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id),
+    token_hash BYTEA NOT NULL,
+    scopes     TEXT[] NOT NULL DEFAULT '{}',
+    issued_at  TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS records (
+    id                   UUID PRIMARY KEY,
+    owner_id             UUID NOT NULL REFERENCES users (id),
+    request_id           UUID,
+    type                 TEXT NOT NULL,
+    name                 TEXT NOT NULL,
+    description          TEXT NOT NULL DEFAULT '',
+    encrypted_data       BYTEA,
+    encrypted_key        BYTEA NOT NULL,
+    alg                  TEXT NOT NULL,
+    compression          TEXT NOT NULL DEFAULT '',
+    s3_key               TEXT NOT NULL DEFAULT '',
+    encrypted_chunk_size BIGINT NOT NULL DEFAULT 0,
+    version              BIGINT NOT NULL DEFAULT 1,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at           TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS records_owner_id_idx ON records (owner_id);
+CREATE INDEX IF NOT EXISTS records_owner_updated_at_idx ON records (owner_id, updated_at);
@@ -0,0 +1,6 @@
+-- The idempotent-create CTE in RecordRepository.Create relies on
+-- ON CONFLICT (owner_id, request_id) WHERE request_id IS NOT NULL, which
+-- needs this partial unique index to match.
+CREATE UNIQUE INDEX IF NOT EXISTS records_owner_request_id_key
+    ON records (owner_id, request_id)
+    WHERE request_id IS NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS record_events (
+    seq         BIGSERIAL PRIMARY KEY,
+    user_id     UUID NOT NULL,
+    record_id   UUID NOT NULL,
+    event_type  TEXT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS record_events_user_seq_idx ON record_events (user_id, seq);
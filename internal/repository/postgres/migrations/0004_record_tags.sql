@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS record_tags (
+    record_id UUID NOT NULL REFERENCES records (id) ON DELETE CASCADE,
+    tag       TEXT NOT NULL,
+    PRIMARY KEY (record_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS record_tags_tag_idx ON record_tags (tag);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS job_checkpoints (
+    job        TEXT PRIMARY KEY,
+    checkpoint TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,3 @@
+ALTER TABLE records ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS records_owner_pinned_idx ON records (owner_id) WHERE pinned;
@@ -0,0 +1,3 @@
+-- Payload size in bytes, measured server-side at upload. Zero marks records
+-- stored before sizes were tracked; the download truncation check skips them.
+ALTER TABLE records ADD COLUMN file_size BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Restrict the idempotent-create conflict to live rows: a create retried
+-- after a soft delete should make a fresh record, not hand back a tombstone.
+-- The ON CONFLICT clause in RecordRepository.Create names the new predicate
+-- and must match this index.
+DROP INDEX IF EXISTS records_owner_request_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS records_owner_request_id_key
+    ON records (owner_id, request_id)
+    WHERE request_id IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Object store entity tag, captured from the upload response. Empty marks
+-- inline records and binaries stored before tags were tracked.
+ALTER TABLE records ADD COLUMN etag TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+-- Per-device tombstone acknowledgments. A row means the device has applied
+-- every tombstone deleted before acked_before; the earliest row across a
+-- user's devices is the high-water mark gating early tombstone purging.
+CREATE TABLE IF NOT EXISTS tombstone_acks (
+    user_id      UUID NOT NULL REFERENCES users (id),
+    device_id    TEXT NOT NULL,
+    acked_before TIMESTAMPTZ NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, device_id)
+);
@@ -0,0 +1,769 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+const recordColumns = `id, owner_id, request_id, type, name, description, encrypted_data,
+	encrypted_key, alg, compression, s3_key, encrypted_chunk_size, file_size, etag, pinned, version, created_at, updated_at, deleted_at`
+
+// RecordRepository persists records in Postgres.
+type RecordRepository struct {
+	db *Connection
+}
+
+// NewRecordRepository creates a record repository.
+func NewRecordRepository(db *Connection) *RecordRepository {
+	return &RecordRepository{db: db}
+}
+
+// Create inserts a record. Creation is idempotent on (owner_id, request_id):
+// when a live record with the same request ID already exists for the owner,
+// the existing row is returned instead of a new insert. Soft-deleted rows do
+// not conflict: a create after a delete makes a fresh record rather than
+// handing back a tombstone. The conflict target relies on the partial unique
+// index over (owner_id, request_id) WHERE request_id IS NOT NULL AND
+// deleted_at IS NULL. A created outbox event is written in the same
+// transaction; an idempotent replay returns the existing row without emitting
+// a second event.
+func (r *RecordRepository) Create(ctx context.Context, record *model.Record) (*model.Record, bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		WITH ins AS (
+			INSERT INTO records (id, owner_id, request_id, type, name, description,
+				encrypted_data, encrypted_key, alg, compression, s3_key, encrypted_chunk_size, file_size, etag, pinned, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, COALESCE($16, now()))
+			ON CONFLICT (owner_id, request_id) WHERE request_id IS NOT NULL AND deleted_at IS NULL DO NOTHING
+			RETURNING `+recordColumns+`
+		)
+		SELECT `+recordColumns+` FROM ins
+		UNION ALL
+		SELECT `+recordColumns+` FROM records
+		WHERE owner_id = $2 AND request_id = $3 AND deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM ins)
+		LIMIT 1`,
+		record.ID, record.OwnerID, record.RequestID, record.Type, record.Name,
+		record.Description, record.EncryptedData, record.EncryptedKey, record.Alg,
+		record.Compression, record.S3Key, record.EncryptedChunkSize, record.FileSize, record.ETag, record.Pinned, createdAtParam(record.CreatedAt),
+	)
+	created, err := scanRecord(row)
+	if err != nil {
+		// The create path relies on the owner_id foreign key instead of a
+		// separate user lookup, so a vanished account surfaces here.
+		if isForeignKeyViolation(err) {
+			return nil, false, apiErrors.NewErrUserNotFound()
+		}
+		return nil, false, err
+	}
+	if created == nil {
+		return nil, false, fmt.Errorf("insert record: no row returned")
+	}
+	// A replayed request returns the previously created row, whose ID differs
+	// from the one generated for this call.
+	inserted := created.ID == record.ID
+	if inserted {
+		if err := replaceTags(ctx, tx, created.ID, record.Tags); err != nil {
+			return nil, false, err
+		}
+		created.Tags = record.Tags
+		if err := insertEvent(ctx, tx, created.OwnerID, created.ID, model.RecordEventCreated); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("commit tx: %w", err)
+	}
+	if !inserted {
+		if err := r.loadTags(ctx, []*model.Record{created}); err != nil {
+			return nil, false, err
+		}
+	}
+	return created, inserted, nil
+}
+
+// GetByID returns a live record by ID, or nil when it does not exist.
+func (r *RecordRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Record, error) {
+	row := r.db.ReadPool(ctx).QueryRow(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE id = $1 AND deleted_at IS NULL`, id,
+	)
+	record, err := scanRecord(row)
+	if err != nil || record == nil {
+		return record, err
+	}
+	if err := r.loadTags(ctx, []*model.Record{record}); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetByIDIncludingDeleted returns a record by ID whether or not it is
+// soft-deleted, or nil when it does not exist. Sync clients use this to
+// confirm a deletion they learned about out of band.
+func (r *RecordRepository) GetByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.Record, error) {
+	row := r.db.ReadPool(ctx).QueryRow(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE id = $1`, id,
+	)
+	record, err := scanRecord(row)
+	if err != nil || record == nil {
+		return record, err
+	}
+	if err := r.loadTags(ctx, []*model.Record{record}); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetByIDs returns the live records among the given IDs. Missing IDs are
+// silently absent from the result.
+func (r *RecordRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Record, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE id = ANY($1) AND deleted_at IS NULL`, ids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+	defer rows.Close()
+	return r.scanRecordsWithTags(ctx, rows)
+}
+
+// GetByUserID returns all live records owned by the user.
+func (r *RecordRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Record, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+	defer rows.Close()
+	return r.scanRecordsWithTags(ctx, rows)
+}
+
+// GetIDsByUserID returns the IDs of all live records owned by the user,
+// skipping the metadata columns entirely.
+func (r *RecordRepository) GetIDsByUserID(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT id FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query record ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan record id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CountByUserID returns the number of live records the user owns. It feeds
+// the quota check before a create, so it reads the primary: a lagging replica
+// would let a client overshoot its limit.
+func (r *RecordRepository) CountByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	if err := r.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL`, userID,
+	).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count records: %w", err)
+	}
+	return count, nil
+}
+
+// UsageByUserID aggregates the user's live records: total stored bytes —
+// inline payloads plus object-backed file sizes — and counts per type.
+// Soft-deleted records are excluded, consistently with CountByUserID.
+func (r *RecordRepository) UsageByUserID(ctx context.Context, userID uuid.UUID) (*model.StorageUsage, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT type, COUNT(*), COALESCE(SUM(octet_length(encrypted_data) + file_size), 0)
+		FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL
+		GROUP BY type`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate usage: %w", err)
+	}
+	defer rows.Close()
+	usage := &model.StorageUsage{CountByType: map[model.RecordType]int{}}
+	for rows.Next() {
+		var recordType model.RecordType
+		var count int
+		var bytes int64
+		if err := rows.Scan(&recordType, &count, &bytes); err != nil {
+			return nil, fmt.Errorf("scan usage row: %w", err)
+		}
+		usage.CountByType[recordType] = count
+		usage.TotalBytes += bytes
+	}
+	return usage, rows.Err()
+}
+
+// GetByUserIDAndTag returns the user's live records carrying the given tag.
+func (r *RecordRepository) GetByUserIDAndTag(ctx context.Context, userID uuid.UUID, tag string) ([]*model.Record, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL
+			AND id IN (SELECT record_id FROM record_tags WHERE tag = $2)
+		ORDER BY created_at`, userID, tag,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+	defer rows.Close()
+	return r.scanRecordsWithTags(ctx, rows)
+}
+
+// GetUpdatedAfter returns live records of the user updated after the given
+// time.
+func (r *RecordRepository) GetUpdatedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]*model.Record, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL AND updated_at > $2
+		ORDER BY updated_at`, userID, after,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+	defer rows.Close()
+	return r.scanRecordsWithTags(ctx, rows)
+}
+
+// GetDeletedAfter returns tombstones of the user's records deleted after the
+// given time.
+func (r *RecordRepository) GetDeletedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]model.Tombstone, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT id, deleted_at FROM records
+		WHERE owner_id = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+		ORDER BY deleted_at`, userID, after,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query tombstones: %w", err)
+	}
+	defer rows.Close()
+
+	var tombstones []model.Tombstone
+	for rows.Next() {
+		var t model.Tombstone
+		if err := rows.Scan(&t.ID, &t.DeletedAt); err != nil {
+			return nil, fmt.Errorf("scan tombstone: %w", err)
+		}
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, rows.Err()
+}
+
+// Rekey replaces the encrypted data key and algorithm of a live record,
+// writing an updated outbox event in the same transaction.
+func (r *RecordRepository) Rekey(ctx context.Context, id uuid.UUID, encryptedKey []byte, alg string, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET encrypted_key = $2, alg = $3, updated_at = $4, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`, id, encryptedKey, alg, at,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("rekey record: not found")
+		}
+		return fmt.Errorf("rekey record: %w", err)
+	}
+	if err := insertEvent(ctx, tx, ownerID, id, model.RecordEventUpdated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// UpdateObjectMeta refreshes a live record's object-derived metadata — file
+// size and ETag — after its stored object was replaced out of band, bumping
+// the version and writing an updated outbox event in the same transaction.
+func (r *RecordRepository) UpdateObjectMeta(ctx context.Context, id uuid.UUID, fileSize int64, etag string, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET file_size = $2, etag = $3, updated_at = $4, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`, id, fileSize, etag, at,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("update object meta: not found")
+		}
+		return fmt.Errorf("update object meta: %w", err)
+	}
+	if err := insertEvent(ctx, tx, ownerID, id, model.RecordEventUpdated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ReplaceContent overwrites a live record's client-mutable fields and bumps
+// the version, writing an updated outbox event in the same transaction. The
+// object-backing columns are reset because the new content is inline; the
+// caller owns deleting a stored object the row pointed at.
+func (r *RecordRepository) ReplaceContent(ctx context.Context, record *model.Record, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET type = $2, name = $3, description = $4, encrypted_data = $5,
+			encrypted_key = $6, alg = $7, compression = $8, pinned = $9,
+			s3_key = '', encrypted_chunk_size = 0, file_size = 0, etag = '',
+			updated_at = $10, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`,
+		record.ID, record.Type, record.Name, record.Description, record.EncryptedData,
+		record.EncryptedKey, record.Alg, record.Compression, record.Pinned, at,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("replace record content: not found")
+		}
+		return fmt.Errorf("replace record content: %w", err)
+	}
+	if err := replaceTags(ctx, tx, record.ID, record.Tags); err != nil {
+		return err
+	}
+	if err := insertEvent(ctx, tx, ownerID, record.ID, model.RecordEventUpdated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// Delete soft-deletes a record, writing a deleted outbox event in the same
+// transaction.
+func (r *RecordRepository) Delete(ctx context.Context, id uuid.UUID, deletedAt time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET deleted_at = $2, updated_at = $2, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`, id, deletedAt,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("delete record: not found")
+		}
+		return fmt.Errorf("delete record: %w", err)
+	}
+	if err := insertEvent(ctx, tx, ownerID, id, model.RecordEventDeleted); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// SetTags replaces a record's tags, bumping updated_at and version and
+// writing an updated outbox event so sync clients pick up the change.
+func (r *RecordRepository) SetTags(ctx context.Context, id uuid.UUID, tags []string, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET updated_at = $2, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`, id, at,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("set tags: not found")
+		}
+		return fmt.Errorf("set tags: %w", err)
+	}
+	if err := replaceTags(ctx, tx, id, tags); err != nil {
+		return err
+	}
+	if err := insertEvent(ctx, tx, ownerID, id, model.RecordEventUpdated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// SetPinned sets the pinned flag, bumping updated_at and version so delta
+// sync picks up the change.
+func (r *RecordRepository) SetPinned(ctx context.Context, id uuid.UUID, pinned bool, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET pinned = $2, updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`, id, pinned, at,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("set pinned: not found")
+		}
+		return fmt.Errorf("set pinned: %w", err)
+	}
+	if err := insertEvent(ctx, tx, ownerID, id, model.RecordEventUpdated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// SetType changes a record's type, bumping updated_at and version and writing
+// an updated outbox event so delta sync picks up the change. Transition rules
+// are enforced by the service; the repository applies the change verbatim.
+func (r *RecordRepository) SetType(ctx context.Context, id uuid.UUID, t model.RecordType, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE records SET type = $2, updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING owner_id`, id, t, at,
+	)
+	var ownerID uuid.UUID
+	if err := row.Scan(&ownerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("set type: not found")
+		}
+		return fmt.Errorf("set type: %w", err)
+	}
+	if err := insertEvent(ctx, tx, ownerID, id, model.RecordEventUpdated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetPinnedByUserID returns the user's live pinned records.
+func (r *RecordRepository) GetPinnedByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Record, error) {
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE owner_id = $1 AND deleted_at IS NULL AND pinned
+		ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query records: %w", err)
+	}
+	defer rows.Close()
+	return r.scanRecordsWithTags(ctx, rows)
+}
+
+// DeleteMany soft-deletes the given records in a single transaction, writing
+// a deleted outbox event per affected row. Rows that are missing or already
+// deleted are skipped rather than failing the batch.
+func (r *RecordRepository) DeleteMany(ctx context.Context, ids []uuid.UUID, deletedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE records SET deleted_at = $2, updated_at = $2, version = version + 1
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		RETURNING id, owner_id`, ids, deletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("delete records: %w", err)
+	}
+	type deleted struct {
+		id      uuid.UUID
+		ownerID uuid.UUID
+	}
+	var affected []deleted
+	for rows.Next() {
+		var d deleted
+		if err := rows.Scan(&d.id, &d.ownerID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan deleted record: %w", err)
+		}
+		affected = append(affected, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("delete records: %w", err)
+	}
+
+	for _, d := range affected {
+		if err := insertEvent(ctx, tx, d.ownerID, d.id, model.RecordEventDeleted); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// TransferOwner reassigns a record to a new owner. The request_id is cleared
+// because it lives in the previous owner's idempotency namespace and could
+// collide with an unrelated create of the target user. Both users get an
+// outbox event so their sync clients converge: the record disappears for the
+// old owner and appears for the new one.
+func (r *RecordRepository) TransferOwner(ctx context.Context, id uuid.UUID, newOwnerID uuid.UUID, at time.Time) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		SELECT owner_id FROM records
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`, id,
+	)
+	var oldOwnerID uuid.UUID
+	if err := row.Scan(&oldOwnerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("transfer record: not found")
+		}
+		return fmt.Errorf("transfer record: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE records SET owner_id = $2, request_id = NULL, updated_at = $3, version = version + 1
+		WHERE id = $1`, id, newOwnerID, at,
+	)
+	if err != nil {
+		return fmt.Errorf("transfer record: %w", err)
+	}
+	if err := insertEvent(ctx, tx, oldOwnerID, id, model.RecordEventDeleted); err != nil {
+		return err
+	}
+	if err := insertEvent(ctx, tx, newOwnerID, id, model.RecordEventCreated); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ListDeletedBefore returns soft-deleted records whose tombstone is older
+// than the cutoff.
+func (r *RecordRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*model.Record, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2`, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query deleted records: %w", err)
+	}
+	defer rows.Close()
+	return scanRecords(rows)
+}
+
+// ListDeletedBeforeForUser is ListDeletedBefore restricted to one user's
+// records.
+func (r *RecordRepository) ListDeletedBeforeForUser(ctx context.Context, userID uuid.UUID, before time.Time, limit int) ([]*model.Record, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+recordColumns+` FROM records
+		WHERE owner_id = $1 AND deleted_at IS NOT NULL AND deleted_at < $2
+		ORDER BY deleted_at
+		LIMIT $3`, userID, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query deleted records: %w", err)
+	}
+	defer rows.Close()
+	return scanRecords(rows)
+}
+
+// HardDelete permanently removes a soft-deleted row. Live rows are left
+// untouched so the purge job can never race a concurrent restore.
+func (r *RecordRepository) HardDelete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM records
+		WHERE id = $1 AND deleted_at IS NOT NULL`, id,
+	); err != nil {
+		return fmt.Errorf("hard delete record: %w", err)
+	}
+	return nil
+}
+
+// createdAtParam maps the record's CreatedAt to an SQL parameter: the zero
+// time becomes NULL so the column default (now()) applies, anything else is a
+// client-preserved timestamp from a backup restore.
+func createdAtParam(createdAt time.Time) *time.Time {
+	if createdAt.IsZero() {
+		return nil
+	}
+	return &createdAt
+}
+
+// replaceTags rewrites the record_tags rows for a record inside the given
+// transaction.
+func replaceTags(ctx context.Context, tx pgx.Tx, recordID uuid.UUID, tags []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM record_tags WHERE record_id = $1`, recordID); err != nil {
+		return fmt.Errorf("delete tags: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO record_tags (record_id, tag)
+		SELECT $1, unnest($2::text[])`, recordID, tags,
+	); err != nil {
+		return fmt.Errorf("insert tags: %w", err)
+	}
+	return nil
+}
+
+// loadTags populates Tags on the given records with a single query.
+func (r *RecordRepository) loadTags(ctx context.Context, records []*model.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(records))
+	byID := make(map[uuid.UUID]*model.Record, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+		byID[record.ID] = record
+	}
+	rows, err := r.db.ReadPool(ctx).Query(ctx, `
+		SELECT record_id, tag FROM record_tags
+		WHERE record_id = ANY($1)
+		ORDER BY tag`, ids,
+	)
+	if err != nil {
+		return fmt.Errorf("query tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var recordID uuid.UUID
+		var tag string
+		if err := rows.Scan(&recordID, &tag); err != nil {
+			return fmt.Errorf("scan tag: %w", err)
+		}
+		if record, ok := byID[recordID]; ok {
+			record.Tags = append(record.Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// scanRecordsWithTags scans the rows and loads the records' tags.
+func (r *RecordRepository) scanRecordsWithTags(ctx context.Context, rows pgx.Rows) ([]*model.Record, error) {
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+	// Row iteration above aborts on cancellation via pgx; check again before
+	// issuing the follow-up tags query for a caller that is already gone.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadTags(ctx, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func scanRecord(row pgx.Row) (*model.Record, error) {
+	record := &model.Record{}
+	err := row.Scan(
+		&record.ID, &record.OwnerID, &record.RequestID, &record.Type, &record.Name,
+		&record.Description, &record.EncryptedData, &record.EncryptedKey, &record.Alg,
+		&record.Compression, &record.S3Key, &record.EncryptedChunkSize, &record.FileSize, &record.ETag, &record.Pinned, &record.Version,
+		&record.CreatedAt, &record.UpdatedAt, &record.DeletedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan record: %w", err)
+	}
+	return record, nil
+}
+
+func scanRecords(rows pgx.Rows) ([]*model.Record, error) {
+	var records []*model.Record
+	for rows.Next() {
+		record := &model.Record{}
+		err := rows.Scan(
+			&record.ID, &record.OwnerID, &record.RequestID, &record.Type, &record.Name,
+			&record.Description, &record.EncryptedData, &record.EncryptedKey, &record.Alg,
+			&record.Compression, &record.S3Key, &record.EncryptedChunkSize, &record.FileSize, &record.ETag, &record.Pinned, &record.Version,
+			&record.CreatedAt, &record.UpdatedAt, &record.DeletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
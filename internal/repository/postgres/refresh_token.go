@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// RefreshTokenRepository persists refresh tokens.
+type RefreshTokenRepository struct {
+	db *Connection
+}
+
+// NewRefreshTokenRepository creates a refresh-token repository.
+func NewRefreshTokenRepository(db *Connection) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create inserts a refresh token.
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, token_hash, scopes, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.ID, token.UserID, token.TokenHash, token.Scopes, token.IssuedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a refresh token by ID, or nil when it does not exist.
+func (r *RefreshTokenRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.RefreshToken, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		SELECT id, user_id, token_hash, scopes, issued_at, expires_at, revoked_at
+		FROM refresh_tokens WHERE id = $1`, id,
+	)
+	token := &model.RefreshToken{}
+	err := row.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.Scopes, &token.IssuedAt, &token.ExpiresAt, &token.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// ListActiveByUser returns the user's unrevoked, unexpired refresh tokens
+// ordered oldest first.
+func (r *RefreshTokenRepository) ListActiveByUser(ctx context.Context, userID uuid.UUID) ([]*model.RefreshToken, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, user_id, token_hash, scopes, issued_at, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY issued_at`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query refresh tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*model.RefreshToken
+	for rows.Next() {
+		token := &model.RefreshToken{}
+		if err := rows.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.Scopes, &token.IssuedAt, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, fmt.Errorf("scan refresh token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke marks a refresh token as revoked.
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = $2
+		WHERE id = $1 AND revoked_at IS NULL`, id, revokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllForUser marks every active refresh token of the user as revoked.
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID, revokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke refresh tokens: %w", err)
+	}
+	return nil
+}
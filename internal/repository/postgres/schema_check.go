@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// HasRequestIDConflictIndex reports whether the partial unique index backing
+// RecordRepository.Create's ON CONFLICT (owner_id, request_id) WHERE
+// request_id IS NOT NULL AND deleted_at IS NULL clause exists. Without it the
+// conflict clause never matches and idempotent creates degrade to ordinary
+// inserts, producing duplicate records under client retries. The check
+// matches on the index definition rather than its name so a hand-created
+// index still counts.
+func HasRequestIDConflictIndex(ctx context.Context, db *Connection) (bool, error) {
+	var count int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM pg_indexes
+		WHERE tablename = 'records'
+			AND indexdef ILIKE '%UNIQUE%'
+			AND indexdef ILIKE '%(owner_id, request_id)%'
+			AND indexdef ILIKE '%request_id IS NOT NULL%'
+			AND indexdef ILIKE '%deleted_at IS NULL%'`,
+	).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("query pg_indexes: %w", err)
+	}
+	return count > 0, nil
+}
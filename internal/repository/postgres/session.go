@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// PendingSignupRepository persists registration sessions.
+type PendingSignupRepository struct {
+	db *Connection
+}
+
+// NewPendingSignupRepository creates a pending-signup repository.
+func NewPendingSignupRepository(db *Connection) *PendingSignupRepository {
+	return &PendingSignupRepository{db: db}
+}
+
+// Create inserts a registration session.
+func (r *PendingSignupRepository) Create(ctx context.Context, signup *model.PendingSignup) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO pending_signups (session_id, email, kdf, server_nonce, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		signup.SessionID, signup.Email, signup.KDF, signup.ServerNonce, signup.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert pending signup: %w", err)
+	}
+	return nil
+}
+
+// GetBySessionID returns a registration session, or nil when it does not
+// exist.
+func (r *PendingSignupRepository) GetBySessionID(ctx context.Context, sessionID uuid.UUID) (*model.PendingSignup, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		SELECT session_id, email, kdf, server_nonce, expires_at, consumed
+		FROM pending_signups WHERE session_id = $1`, sessionID,
+	)
+	signup := &model.PendingSignup{}
+	err := row.Scan(&signup.SessionID, &signup.Email, &signup.KDF, &signup.ServerNonce, &signup.ExpiresAt, &signup.Consumed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan pending signup: %w", err)
+	}
+	return signup, nil
+}
+
+// CountActiveByEmail counts the unconsumed, unexpired registration sessions
+// for an email.
+func (r *PendingSignupRepository) CountActiveByEmail(ctx context.Context, email string) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM pending_signups
+		WHERE email = $1 AND NOT consumed AND expires_at > now()`, email,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count pending signups: %w", err)
+	}
+	return count, nil
+}
+
+// Consume marks a registration session as used.
+func (r *PendingSignupRepository) Consume(ctx context.Context, sessionID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE pending_signups SET consumed = TRUE WHERE session_id = $1`, sessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("consume pending signup: %w", err)
+	}
+	return nil
+}
+
+// PendingLoginRepository persists login sessions.
+type PendingLoginRepository struct {
+	db *Connection
+}
+
+// NewPendingLoginRepository creates a pending-login repository.
+func NewPendingLoginRepository(db *Connection) *PendingLoginRepository {
+	return &PendingLoginRepository{db: db}
+}
+
+// Create inserts a login session.
+func (r *PendingLoginRepository) Create(ctx context.Context, login *model.PendingLogin) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO pending_logins (session_id, user_id, login, client_nonce, server_nonce, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		login.SessionID, login.UserID, login.Login, login.ClientNonce, login.ServerNonce, login.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert pending login: %w", err)
+	}
+	return nil
+}
+
+// GetBySessionID returns a login session, or nil when it does not exist.
+func (r *PendingLoginRepository) GetBySessionID(ctx context.Context, sessionID uuid.UUID) (*model.PendingLogin, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		SELECT session_id, user_id, login, client_nonce, server_nonce, expires_at, consumed
+		FROM pending_logins WHERE session_id = $1`, sessionID,
+	)
+	login := &model.PendingLogin{}
+	err := row.Scan(&login.SessionID, &login.UserID, &login.Login, &login.ClientNonce, &login.ServerNonce, &login.ExpiresAt, &login.Consumed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan pending login: %w", err)
+	}
+	return login, nil
+}
+
+// GetActiveByLogin returns the most recently created unconsumed, unexpired
+// login session for the login, or nil when there is none.
+func (r *PendingLoginRepository) GetActiveByLogin(ctx context.Context, login string) (*model.PendingLogin, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		SELECT session_id, user_id, login, client_nonce, server_nonce, expires_at, consumed
+		FROM pending_logins
+		WHERE login = $1 AND NOT consumed AND expires_at > now()
+		ORDER BY expires_at DESC LIMIT 1`, login,
+	)
+	pending := &model.PendingLogin{}
+	err := row.Scan(&pending.SessionID, &pending.UserID, &pending.Login, &pending.ClientNonce, &pending.ServerNonce, &pending.ExpiresAt, &pending.Consumed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan pending login: %w", err)
+	}
+	return pending, nil
+}
+
+// CountActiveByLogin counts the unconsumed, unexpired login sessions for a
+// login.
+func (r *PendingLoginRepository) CountActiveByLogin(ctx context.Context, login string) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM pending_logins
+		WHERE login = $1 AND NOT consumed AND expires_at > now()`, login,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count pending logins: %w", err)
+	}
+	return count, nil
+}
+
+// Consume marks a login session as used.
+func (r *PendingLoginRepository) Consume(ctx context.Context, sessionID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE pending_logins SET consumed = TRUE WHERE session_id = $1`, sessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("consume pending login: %w", err)
+	}
+	return nil
+}
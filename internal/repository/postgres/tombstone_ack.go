@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TombstoneAckRepository persists per-device tombstone acknowledgments.
+type TombstoneAckRepository struct {
+	db *Connection
+}
+
+// NewTombstoneAckRepository creates a tombstone-acknowledgment repository.
+func NewTombstoneAckRepository(db *Connection) *TombstoneAckRepository {
+	return &TombstoneAckRepository{db: db}
+}
+
+// Upsert records the device's acknowledgment. GREATEST keeps the stored value
+// monotonic: a delayed or replayed request can never move an acknowledgment
+// backwards and revive tombstones the purge already relied on.
+func (r *TombstoneAckRepository) Upsert(ctx context.Context, userID uuid.UUID, deviceID string, ackedBefore, at time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO tombstone_acks (user_id, device_id, acked_before, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, device_id) DO UPDATE
+		SET acked_before = GREATEST(tombstone_acks.acked_before, EXCLUDED.acked_before),
+		    updated_at   = EXCLUDED.updated_at`,
+		userID, deviceID, ackedBefore, at,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert tombstone ack: %w", err)
+	}
+	return nil
+}
+
+// HighWaterMark returns the earliest acknowledged timestamp across the user's
+// devices, or the zero time when the user has no acknowledgments.
+func (r *TombstoneAckRepository) HighWaterMark(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	// The aggregate always yields one row; MIN over no rows is NULL.
+	var mark *time.Time
+	err := r.db.ReadPool(ctx).QueryRow(ctx, `
+		SELECT MIN(acked_before) FROM tombstone_acks WHERE user_id = $1`, userID,
+	).Scan(&mark)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query ack high-water mark: %w", err)
+	}
+	if mark == nil {
+		return time.Time{}, nil
+	}
+	return *mark, nil
+}
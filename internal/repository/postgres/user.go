@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// Postgres SQLSTATEs for the constraint violations the repositories translate
+// into typed errors.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// isUniqueViolation reports whether the error is a unique constraint
+// violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// isForeignKeyViolation reports whether the error is a foreign key constraint
+// violation.
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation
+}
+
+// UserRepository persists users in Postgres.
+type UserRepository struct {
+	db *Connection
+}
+
+// NewUserRepository creates a user repository.
+func NewUserRepository(db *Connection) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// Create inserts a new user and returns it with server-side fields populated.
+// A duplicate email is reported as apierrors.NewErrEmailIsTaken so the
+// concurrent-registration race (the existence pre-check in CompleteReg passing
+// for both requests) surfaces as a clean conflict instead of an internal
+// error.
+func (r *UserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO users (id, email, verifier, salt, kdf)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, email, verifier, salt, kdf, created_at`,
+		user.ID, user.Email, user.Verifier, user.Salt, user.KDF,
+	)
+	created := &model.User{}
+	if err := row.Scan(&created.ID, &created.Email, &created.Verifier, &created.Salt, &created.KDF, &created.CreatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return nil, apiErrors.NewErrEmailIsTaken(user.Email)
+		}
+		return nil, fmt.Errorf("insert user: %w", err)
+	}
+	return created, nil
+}
+
+// GetByID returns a user by ID, or nil when no such user exists.
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		SELECT id, email, verifier, salt, kdf, created_at
+		FROM users WHERE id = $1`, id,
+	)
+	return scanUser(row)
+}
+
+// GetByEmail returns a user by email, or nil when no such user exists. The
+// lookup is case-insensitive so rows written before emails were normalized
+// are still found.
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	row := r.db.Pool.QueryRow(ctx, `
+		SELECT id, email, verifier, salt, kdf, created_at
+		FROM users WHERE lower(email) = lower($1)`, email,
+	)
+	return scanUser(row)
+}
+
+// List returns up to limit users with IDs greater than afterID, in ID order.
+// It backs maintenance scans that page through the whole table.
+func (r *UserRepository) List(ctx context.Context, afterID uuid.UUID, limit int) ([]*model.User, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, email, verifier, salt, kdf, created_at
+		FROM users WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+	var users []*model.User
+	for rows.Next() {
+		user := &model.User{}
+		if err := rows.Scan(&user.ID, &user.Email, &user.Verifier, &user.Salt, &user.KDF, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateKDF overwrites the stored KDF parameter blob.
+func (r *UserRepository) UpdateKDF(ctx context.Context, id uuid.UUID, kdf []byte) error {
+	if _, err := r.db.Pool.Exec(ctx, `UPDATE users SET kdf = $2 WHERE id = $1`, id, kdf); err != nil {
+		return fmt.Errorf("update user kdf: %w", err)
+	}
+	return nil
+}
+
+func scanUser(row pgx.Row) (*model.User, error) {
+	user := &model.User{}
+	err := row.Scan(&user.ID, &user.Email, &user.Verifier, &user.Salt, &user.KDF, &user.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "unique violation",
+			err:  &pgconn.PgError{Code: uniqueViolation},
+			want: true,
+		},
+		{
+			name: "wrapped unique violation",
+			err:  fmt.Errorf("insert user: %w", &pgconn.PgError{Code: uniqueViolation}),
+			want: true,
+		},
+		{
+			name: "other pg error",
+			err:  &pgconn.PgError{Code: "23503"},
+			want: false,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("isUniqueViolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsForeignKeyViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "foreign key violation",
+			err:  &pgconn.PgError{Code: foreignKeyViolation},
+			want: true,
+		},
+		{
+			name: "wrapped foreign key violation",
+			err:  fmt.Errorf("scan record: %w", &pgconn.PgError{Code: foreignKeyViolation}),
+			want: true,
+		},
+		{
+			name: "unique violation",
+			err:  &pgconn.PgError{Code: uniqueViolation},
+			want: false,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isForeignKeyViolation(tt.err); got != tt.want {
+				t.Errorf("isForeignKeyViolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Package server wraps the gRPC server lifecycle.
+package server
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+// GRPCServer runs the gRPC server on a listener produced by the security
+// layer.
+type GRPCServer struct {
+	server   *grpc.Server
+	security SecurityLayer
+	address  string
+	logger   *logger.Logger
+}
+
+// NewGRPCServer creates the server wrapper.
+func NewGRPCServer(server *grpc.Server, security SecurityLayer, address string, l *logger.Logger) *GRPCServer {
+	return &GRPCServer{
+		server:   server,
+		security: security,
+		address:  address,
+		logger:   l,
+	}
+}
+
+// Start opens the listener on the given network (tcp or unix) and serves
+// until Stop is called.
+func (s *GRPCServer) Start(protocol string) error {
+	listener, err := s.security.Listen(protocol, s.address)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	s.logger.Info("grpc server listening", "network", protocol, "address", s.address)
+	if err := s.server.Serve(listener); err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+// Stop stops the server immediately.
+func (s *GRPCServer) Stop() {
+	s.server.Stop()
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+)
+
+// SecurityLayer produces the network listener the gRPC server serves on.
+type SecurityLayer interface {
+	Listen(protocol, address string) (net.Listener, error)
+}
+
+// ListenOptions tunes the TCP socket the listeners bind. The zero value keeps
+// the operating system defaults. Both options apply to TCP only and are
+// Linux-specific; unix sockets ignore them.
+type ListenOptions struct {
+	// Backlog is the accept-queue length passed to listen(2); the kernel
+	// still caps it at net.core.somaxconn. Zero keeps the Go default, which
+	// asks for the kernel maximum.
+	Backlog int
+	// ReusePort sets SO_REUSEPORT before bind, so several server processes
+	// can share the address with the kernel load-balancing accepted
+	// connections among their accept loops.
+	ReusePort bool
+}
+
+// PlainListener listens without transport security.
+type PlainListener struct {
+	opts ListenOptions
+}
+
+// NewPlainListener creates a plaintext security layer.
+func NewPlainListener(opts ListenOptions) *PlainListener {
+	return &PlainListener{opts: opts}
+}
+
+// Listen opens a plaintext listener on the address over the given network
+// (tcp or unix).
+func (l *PlainListener) Listen(protocol, address string) (net.Listener, error) {
+	return listen(protocol, address, l.opts)
+}
+
+// TLSListener listens with TLS using a certificate loaded either from files
+// or from in-memory PEM blocks.
+type TLSListener struct {
+	certFile string
+	keyFile  string
+	certPEM  []byte
+	keyPEM   []byte
+	opts     ListenOptions
+}
+
+// NewTLSListener creates a TLS security layer from certificate and key files.
+func NewTLSListener(certFile, keyFile string, opts ListenOptions) *TLSListener {
+	return &TLSListener{certFile: certFile, keyFile: keyFile, opts: opts}
+}
+
+// NewTLSListenerFromPEM creates a TLS security layer from in-memory PEM
+// blocks, for deployments where the certificate arrives via the environment
+// or a secret manager instead of the filesystem.
+func NewTLSListenerFromPEM(certPEM, keyPEM []byte, opts ListenOptions) *TLSListener {
+	return &TLSListener{certPEM: certPEM, keyPEM: keyPEM, opts: opts}
+}
+
+// Listen opens a TLS listener on the address over the given network (tcp or
+// unix).
+func (l *TLSListener) Listen(protocol, address string) (net.Listener, error) {
+	var cert tls.Certificate
+	var err error
+	if len(l.certPEM) > 0 {
+		cert, err = tls.X509KeyPair(l.certPEM, l.keyPEM)
+	} else {
+		cert, err = tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load key pair: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	listener, err := listen(protocol, address, l.opts)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(listener, config), nil
+}
+
+// listen opens the raw listener, applying the socket options on TCP. An
+// explicit backlog bypasses the net package, which always asks listen(2) for
+// the kernel maximum; SO_REUSEPORT alone fits through a ListenConfig control
+// hook.
+func listen(protocol, address string, opts ListenOptions) (net.Listener, error) {
+	if err := removeStaleSocket(protocol, address); err != nil {
+		return nil, err
+	}
+	if protocol == "tcp" && opts.Backlog > 0 {
+		return listenTCPBacklog(address, opts)
+	}
+	var lc net.ListenConfig
+	if protocol == "tcp" && opts.ReusePort {
+		lc.Control = reusePortControl
+	}
+	listener, err := lc.Listen(context.Background(), protocol, address)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return listener, nil
+}
+
+// removeStaleSocket unlinks a leftover unix socket file from a previous run
+// so the new listener can bind. The listener created afterwards removes its
+// own file again when closed on shutdown.
+func removeStaleSocket(protocol, address string) error {
+	if protocol != "unix" {
+		return nil
+	}
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// reusePortControl sets SO_REUSEPORT on the socket before bind.
+func reusePortControl(_, _ string, c syscall.RawConn) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1)
+	}); err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("set SO_REUSEPORT: %w", sockErr)
+	}
+	return nil
+}
+
+// listenTCPBacklog opens a TCP listener with an explicit accept-queue length.
+// The socket is assembled by hand — socket, options, bind, listen — and then
+// adopted through net.FileListener, because the net package offers no way to
+// pass a backlog of its own. The kernel still caps the value at
+// net.core.somaxconn.
+func listenTCPBacklog(address string, opts ListenOptions) (net.Listener, error) {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("resolve address: %w", err)
+	}
+	ip := addr.IP
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+	var (
+		domain int
+		sa     syscall.Sockaddr
+	)
+	if ip4 := ip.To4(); ip4 != nil {
+		domain = syscall.AF_INET
+		sa4 := &syscall.SockaddrInet4{Port: addr.Port}
+		copy(sa4.Addr[:], ip4)
+		sa = sa4
+	} else {
+		domain = syscall.AF_INET6
+		sa6 := &syscall.SockaddrInet6{Port: addr.Port}
+		copy(sa6.Addr[:], ip.To16())
+		sa = sa6
+	}
+
+	fd, err := syscall.Socket(domain, syscall.SOCK_STREAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("create socket: %w", err)
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("set SO_REUSEADDR: %w", err)
+	}
+	if opts.ReusePort {
+		if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1); err != nil {
+			syscall.Close(fd)
+			return nil, fmt.Errorf("set SO_REUSEPORT: %w", err)
+		}
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("bind: %w", err)
+	}
+	if err := syscall.Listen(fd, opts.Backlog); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	file := os.NewFile(uintptr(fd), address)
+	listener, err := net.FileListener(file)
+	// FileListener duplicates the descriptor, so the original is closed on
+	// both paths.
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("adopt socket: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// reusePortControl rejects the option: SO_REUSEPORT load balancing across
+// accept loops is relied upon as a Linux behavior here.
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is only supported on linux")
+}
+
+// listenTCPBacklog rejects the option on platforms where the hand-assembled
+// socket path is not implemented.
+func listenTCPBacklog(_ string, _ ListenOptions) (net.Listener, error) {
+	return nil, fmt.Errorf("listen backlog tuning is only supported on linux")
+}
@@ -0,0 +1,657 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/middleware"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+type mockSignupStore struct {
+	signups map[uuid.UUID]*model.PendingSignup
+}
+
+func newMockSignupStore() *mockSignupStore {
+	return &mockSignupStore{signups: map[uuid.UUID]*model.PendingSignup{}}
+}
+
+func (m *mockSignupStore) Create(_ context.Context, signup *model.PendingSignup) error {
+	m.signups[signup.SessionID] = signup
+	return nil
+}
+
+func (m *mockSignupStore) GetBySessionID(_ context.Context, sessionID uuid.UUID) (*model.PendingSignup, error) {
+	return m.signups[sessionID], nil
+}
+
+func (m *mockSignupStore) CountActiveByEmail(_ context.Context, email string) (int, error) {
+	count := 0
+	for _, s := range m.signups {
+		if s.Email == email && !s.Consumed && s.ExpiresAt.After(time.Now()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockSignupStore) Consume(_ context.Context, sessionID uuid.UUID) error {
+	if s, ok := m.signups[sessionID]; ok {
+		s.Consumed = true
+	}
+	return nil
+}
+
+type mockLoginStore struct {
+	logins map[uuid.UUID]*model.PendingLogin
+}
+
+func newMockLoginStore() *mockLoginStore {
+	return &mockLoginStore{logins: map[uuid.UUID]*model.PendingLogin{}}
+}
+
+func (m *mockLoginStore) Create(_ context.Context, login *model.PendingLogin) error {
+	m.logins[login.SessionID] = login
+	return nil
+}
+
+func (m *mockLoginStore) GetBySessionID(_ context.Context, sessionID uuid.UUID) (*model.PendingLogin, error) {
+	return m.logins[sessionID], nil
+}
+
+func (m *mockLoginStore) GetActiveByLogin(_ context.Context, login string) (*model.PendingLogin, error) {
+	var latest *model.PendingLogin
+	for _, l := range m.logins {
+		if l.Login != login || l.Consumed || !l.ExpiresAt.After(time.Now()) {
+			continue
+		}
+		if latest == nil || l.ExpiresAt.After(latest.ExpiresAt) {
+			latest = l
+		}
+	}
+	return latest, nil
+}
+
+func (m *mockLoginStore) CountActiveByLogin(_ context.Context, login string) (int, error) {
+	count := 0
+	for _, l := range m.logins {
+		if l.Login == login && !l.Consumed && l.ExpiresAt.After(time.Now()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockLoginStore) Consume(_ context.Context, sessionID uuid.UUID) error {
+	if l, ok := m.logins[sessionID]; ok {
+		l.Consumed = true
+	}
+	return nil
+}
+
+type mockTokenStore struct {
+	tokens map[uuid.UUID]*model.RefreshToken
+}
+
+func newMockTokenStore() *mockTokenStore {
+	return &mockTokenStore{tokens: map[uuid.UUID]*model.RefreshToken{}}
+}
+
+func (m *mockTokenStore) Create(_ context.Context, token *model.RefreshToken) error {
+	m.tokens[token.ID] = token
+	return nil
+}
+
+func (m *mockTokenStore) GetByID(_ context.Context, id uuid.UUID) (*model.RefreshToken, error) {
+	return m.tokens[id], nil
+}
+
+func (m *mockTokenStore) ListActiveByUser(_ context.Context, userID uuid.UUID) ([]*model.RefreshToken, error) {
+	var active []*model.RefreshToken
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil && t.ExpiresAt.After(time.Now()) {
+			active = append(active, t)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].IssuedAt.Before(active[j].IssuedAt) })
+	return active, nil
+}
+
+func (m *mockTokenStore) Revoke(_ context.Context, id uuid.UUID, revokedAt time.Time) error {
+	if t, ok := m.tokens[id]; ok && t.RevokedAt == nil {
+		t.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+func (m *mockTokenStore) RevokeAllForUser(_ context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			at := revokedAt
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func newAuthService(users *mockUserStore, signups *mockSignupStore, logins *mockLoginStore) *Auth {
+	tokens := NewTokenService(newMockTokenStore(), []byte("secret"), time.Minute, time.Hour, 0, 0, logger.NewNoop())
+	return NewAuth(users, signups, logins, tokens,
+		model.NewKDFParams(3, 65536, 4), model.NewKDFParams(2, 19456, 1),
+		DefaultPendingSessionTTL, 0, 0, false, logger.NewNoop())
+}
+
+func TestGetRegParams_EmailTaken(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "taken@example.com"}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	_, err := svc.GetRegParams(context.Background(), "taken@example.com")
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want APIError", err)
+	}
+}
+
+func TestGetRegParams_CreatesPendingSession(t *testing.T) {
+	signups := newMockSignupStore()
+	svc := newAuthService(newMockUserStore(), signups, newMockLoginStore())
+
+	params, err := svc.GetRegParams(context.Background(), "new@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := signups.signups[params.SessionID]; !ok {
+		t.Error("pending signup not created")
+	}
+	if params.KDF.Time != 3 || params.KDF.MemKiB != 65536 {
+		t.Errorf("kdf = %+v, want server policy", params.KDF)
+	}
+}
+
+func TestGetRegParams_RejectsInvalidEmail(t *testing.T) {
+	svc := newAuthService(newMockUserStore(), newMockSignupStore(), newMockLoginStore())
+
+	for _, email := range []string{"", "nodomain", "@x.com", "a@b"} {
+		if _, err := svc.GetRegParams(context.Background(), email); err == nil {
+			t.Errorf("GetRegParams(%q): expected error", email)
+		}
+	}
+}
+
+func TestGetRegParams_NormalizesEmail(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "taken@example.com"}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	_, err := svc.GetRegParams(context.Background(), "  Taken@Example.COM ")
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want email-taken error for differently cased email", err)
+	}
+}
+
+func TestCompleteLogin_UnknownSession(t *testing.T) {
+	svc := newAuthService(newMockUserStore(), newMockSignupStore(), newMockLoginStore())
+
+	_, err := svc.CompleteLogin(context.Background(), LoginComplete{SessionID: uuid.New()})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "session not found" {
+		t.Errorf("err = %v, want session not found", err)
+	}
+}
+
+func TestCompleteLogin_KDFUpgradeRecommended(t *testing.T) {
+	users := newMockUserStore()
+	logins := newMockLoginStore()
+	svc := newAuthService(users, newMockSignupStore(), logins)
+
+	userID := uuid.New()
+	verifier := []byte("verifier-material")
+	users.users[userID] = &model.User{
+		ID:       userID,
+		Email:    "weak@example.com",
+		Verifier: verifier,
+		KDF:      []byte(`{"alg":"argon2id","time":1,"mem_kib":1024,"par":1}`),
+	}
+	login := &model.PendingLogin{
+		SessionID:   uuid.New(),
+		UserID:      userID,
+		ServerNonce: []byte("server"),
+		ClientNonce: []byte("client"),
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}
+	logins.logins[login.SessionID] = login
+
+	result, err := svc.CompleteLogin(context.Background(), LoginComplete{
+		SessionID:   login.SessionID,
+		ClientProof: computeProof(verifier, login.ServerNonce, login.ClientNonce),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.KDFUpgradeRecommended {
+		t.Error("KDFUpgradeRecommended = false, want true for weaker stored params")
+	}
+}
+
+func TestCompleteLogin_IssuesRequestedScopes(t *testing.T) {
+	users := newMockUserStore()
+	logins := newMockLoginStore()
+	tokenStore := newMockTokenStore()
+	tokens := NewTokenService(tokenStore, []byte("secret"), time.Minute, time.Hour, 0, 0, logger.NewNoop())
+	svc := NewAuth(users, newMockSignupStore(), logins, tokens,
+		model.NewKDFParams(3, 65536, 4), model.NewKDFParams(2, 19456, 1),
+		DefaultPendingSessionTTL, 0, 0, false, logger.NewNoop())
+
+	userID := uuid.New()
+	verifier := []byte("verifier-material")
+	users.users[userID] = &model.User{
+		ID:       userID,
+		Email:    "scoped@example.com",
+		Verifier: verifier,
+		KDF:      []byte(`{"alg":"argon2id","time":3,"mem_kib":65536,"par":4}`),
+	}
+	login := &model.PendingLogin{
+		SessionID:   uuid.New(),
+		UserID:      userID,
+		ServerNonce: []byte("server"),
+		ClientNonce: []byte("client"),
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}
+	logins.logins[login.SessionID] = login
+
+	_, err := svc.CompleteLogin(context.Background(), LoginComplete{
+		SessionID:   login.SessionID,
+		ClientProof: computeProof(verifier, login.ServerNonce, login.ClientNonce),
+		Scopes:      []string{middleware.ScopeRecordsRead},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tokenStore.tokens) != 1 {
+		t.Fatalf("refresh tokens = %d, want 1", len(tokenStore.tokens))
+	}
+	for _, token := range tokenStore.tokens {
+		if len(token.Scopes) != 1 || token.Scopes[0] != middleware.ScopeRecordsRead {
+			t.Errorf("refresh token scopes = %v, want [%s]", token.Scopes, middleware.ScopeRecordsRead)
+		}
+	}
+}
+
+func TestCompleteLogin_RejectsNonGrantableScopes(t *testing.T) {
+	svc := newAuthService(newMockUserStore(), newMockSignupStore(), newMockLoginStore())
+
+	for _, scope := range []string{middleware.ScopeAdmin, "records:delete"} {
+		_, err := svc.CompleteLogin(context.Background(), LoginComplete{
+			SessionID: uuid.New(),
+			Scopes:    []string{scope},
+		})
+		var apiErr *apiErrors.APIError
+		if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidScope {
+			t.Errorf("scope %q: err = %v, want reason %s", scope, err, apiErrors.ReasonInvalidScope)
+		}
+	}
+}
+
+func TestCompleteReg_RejectsKDFMismatch(t *testing.T) {
+	signups := newMockSignupStore()
+	svc := newAuthService(newMockUserStore(), signups, newMockLoginStore())
+
+	params, err := svc.GetRegParams(context.Background(), "new@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	verifier := []byte("verifier-material")
+	weak := model.NewKDFParams(1, 1024, 1)
+	_, err = svc.CompleteReg(context.Background(), RegComplete{
+		SessionID:   params.SessionID,
+		Email:       "new@example.com",
+		Verifier:    verifier,
+		Salt:        []byte("salt-material"),
+		KDF:         weak,
+		ClientProof: computeProof(verifier, params.ServerNonce, nil),
+	})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want APIError for downgraded kdf params", err)
+	}
+}
+
+func TestCompleteReg_ReplaysAfterLostResponse(t *testing.T) {
+	users := newMockUserStore()
+	signups := newMockSignupStore()
+	svc := newAuthService(users, signups, newMockLoginStore())
+
+	params, err := svc.GetRegParams(context.Background(), "new@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	verifier := []byte("verifier-material")
+	complete := RegComplete{
+		SessionID:   params.SessionID,
+		Email:       "new@example.com",
+		Verifier:    verifier,
+		Salt:        []byte("salt-material"),
+		KDF:         params.KDF,
+		ClientProof: computeProof(verifier, params.ServerNonce, nil),
+	}
+	if _, err := svc.CompleteReg(context.Background(), complete); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The client retries after losing the response; the consumed session
+	// with matching credentials must replay as success.
+	result, err := svc.CompleteReg(context.Background(), complete)
+	if err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if result.AccessToken == "" || result.RefreshToken == "" {
+		t.Error("retry returned empty tokens")
+	}
+	if len(users.users) != 1 {
+		t.Errorf("expected a single user after retry, got %d", len(users.users))
+	}
+
+	// A retry carrying different credential material must not mint tokens.
+	tampered := complete
+	tampered.Verifier = []byte("a-different-verifier")
+	tampered.ClientProof = computeProof(tampered.Verifier, params.ServerNonce, nil)
+	if _, err := svc.CompleteReg(context.Background(), tampered); err == nil {
+		t.Fatal("expected error for retry with different verifier")
+	}
+}
+
+func TestGetRegParams_PrivacyModeHidesTakenEmail(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "taken@example.com"}
+	signups := newMockSignupStore()
+	tokens := NewTokenService(newMockTokenStore(), []byte("secret"), time.Minute, time.Hour, 0, 0, logger.NewNoop())
+	svc := NewAuth(users, signups, newMockLoginStore(), tokens,
+		model.NewKDFParams(3, 65536, 4), model.NewKDFParams(2, 19456, 1),
+		DefaultPendingSessionTTL, 0, 0, true, logger.NewNoop())
+
+	params, err := svc.GetRegParams(context.Background(), "taken@example.com")
+	if err != nil {
+		t.Fatalf("privacy mode must not reveal the taken email: %v", err)
+	}
+	if params.SessionID == uuid.Nil || len(params.ServerNonce) == 0 {
+		t.Fatal("expected genuine-looking registration params")
+	}
+
+	// The conflict only surfaces when the client commits.
+	verifier := []byte("verifier-material")
+	_, err = svc.CompleteReg(context.Background(), RegComplete{
+		SessionID:   params.SessionID,
+		Email:       "taken@example.com",
+		Verifier:    verifier,
+		Salt:        []byte("salt-material"),
+		KDF:         params.KDF,
+		ClientProof: computeProof(verifier, params.ServerNonce, nil),
+	})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonEmailTaken {
+		t.Fatalf("err = %v, want email taken at CompleteReg", err)
+	}
+}
+
+func TestAuth_RejectsOutOfBoundsFieldLengths(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "user@example.com"}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	for _, nonce := range [][]byte{nil, []byte("short"), make([]byte, 65)} {
+		_, err := svc.GetLoginParams(context.Background(), LoginParams{
+			Login:       "user@example.com",
+			ClientNonce: nonce,
+		})
+		var apiErr *apiErrors.APIError
+		if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidFieldLength {
+			t.Errorf("GetLoginParams with %d-byte nonce: err = %v, want invalid field length", len(nonce), err)
+		}
+	}
+
+	complete := RegComplete{
+		SessionID: uuid.New(),
+		Email:     "new@example.com",
+		Verifier:  []byte("verifier-material"),
+		Salt:      make([]byte, 65),
+	}
+	if _, err := svc.CompleteReg(context.Background(), complete); err == nil {
+		t.Error("expected error for oversized salt")
+	}
+	complete.Salt = []byte("salt-material")
+	complete.Verifier = []byte("short")
+	var apiErr *apiErrors.APIError
+	if _, err := svc.CompleteReg(context.Background(), complete); !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidFieldLength {
+		t.Errorf("CompleteReg with short verifier: err = %v, want invalid field length", err)
+	}
+}
+
+func TestAuth_CapsPendingSessionsPerLogin(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "user@example.com", KDF: []byte(`{"alg":"argon2id","time":3,"mem_kib":65536,"par":4}`)}
+	signups := newMockSignupStore()
+	logins := newMockLoginStore()
+	tokens := NewTokenService(newMockTokenStore(), []byte("secret"), time.Minute, time.Hour, 0, 0, logger.NewNoop())
+	svc := NewAuth(users, signups, logins, tokens,
+		model.NewKDFParams(3, 65536, 4), model.NewKDFParams(2, 19456, 1),
+		DefaultPendingSessionTTL, 0, 2, false, logger.NewNoop())
+
+	nonce := []byte("client-nonce-0123456789abcdef")
+	for i := 0; i < 2; i++ {
+		if _, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: nonce}); err != nil {
+			t.Fatalf("login %d: unexpected error: %v", i, err)
+		}
+	}
+	_, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: nonce})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonTooManyPending {
+		t.Fatalf("err = %v, want too many pending sessions", err)
+	}
+
+	// An expired session frees its slot without being consumed.
+	for _, l := range logins.logins {
+		l.ExpiresAt = time.Now().Add(-time.Minute)
+		break
+	}
+	if _, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: nonce}); err != nil {
+		t.Fatalf("after expiry: unexpected error: %v", err)
+	}
+
+	// Registration sessions are capped independently per email.
+	for i := 0; i < 2; i++ {
+		if _, err := svc.GetRegParams(context.Background(), "new@example.com"); err != nil {
+			t.Fatalf("signup %d: unexpected error: %v", i, err)
+		}
+	}
+	_, err = svc.GetRegParams(context.Background(), "new@example.com")
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonTooManyPending {
+		t.Fatalf("err = %v, want too many pending sessions", err)
+	}
+	if _, err := svc.GetRegParams(context.Background(), "other@example.com"); err != nil {
+		t.Fatalf("different email: unexpected error: %v", err)
+	}
+}
+
+func TestCheckEmailRegistered(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "taken@example.com"}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	registered, err := svc.CheckEmailRegistered(context.Background(), "Taken@Example.com ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !registered {
+		t.Error("registered = false for an existing account")
+	}
+
+	registered, err = svc.CheckEmailRegistered(context.Background(), "free@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if registered {
+		t.Error("registered = true for an unknown email")
+	}
+
+	if _, err := svc.CheckEmailRegistered(context.Background(), "nodomain"); err == nil {
+		t.Error("expected error for a malformed email")
+	}
+}
+
+func TestGetLoginParams_CorruptStoredKDF(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{ID: userID, Email: "user@example.com", KDF: []byte("{not json")}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	_, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: make([]byte, 16)})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want APIError", err)
+	}
+	if apiErr.Reason != apiErrors.ReasonCorruptKDF {
+		t.Errorf("reason = %q, want %q", apiErr.Reason, apiErrors.ReasonCorruptKDF)
+	}
+}
+
+func TestGetLoginParams_RepairsDoubleEncodedKDF(t *testing.T) {
+	users := newMockUserStore()
+	userID := uuid.New()
+	params := model.NewKDFParams(3, 65536, 4)
+	canonical, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	wrapped, err := json.Marshal(string(canonical))
+	if err != nil {
+		t.Fatalf("wrap params: %v", err)
+	}
+	users.users[userID] = &model.User{ID: userID, Email: "user@example.com", KDF: wrapped}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	challenge, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: make([]byte, 16)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.KDF != params {
+		t.Errorf("kdf = %+v, want recovered %+v", challenge.KDF, params)
+	}
+	if !bytes.Equal(users.users[userID].KDF, canonical) {
+		t.Errorf("stored kdf = %q, want canonical %q", users.users[userID].KDF, canonical)
+	}
+}
+
+func TestRepairKDFBlobs_RepairsRecoverableCountsCorrupt(t *testing.T) {
+	users := newMockUserStore()
+	params := model.NewKDFParams(3, 65536, 4)
+	canonical, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	intactID := uuid.New()
+	users.users[intactID] = &model.User{ID: intactID, KDF: canonical}
+	wrapped, err := json.Marshal(string(canonical))
+	if err != nil {
+		t.Fatalf("wrap params: %v", err)
+	}
+	wrappedID := uuid.New()
+	users.users[wrappedID] = &model.User{ID: wrappedID, KDF: wrapped}
+	corruptID := uuid.New()
+	users.users[corruptID] = &model.User{ID: corruptID, KDF: []byte("argon2id")}
+	svc := newAuthService(users, newMockSignupStore(), newMockLoginStore())
+
+	repaired, corrupt, err := svc.RepairKDFBlobs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repaired != 1 || corrupt != 1 {
+		t.Errorf("repaired, corrupt = %d, %d, want 1, 1", repaired, corrupt)
+	}
+	if !bytes.Equal(users.users[wrappedID].KDF, canonical) {
+		t.Errorf("wrapped blob = %q, want canonical %q", users.users[wrappedID].KDF, canonical)
+	}
+	if !bytes.Equal(users.users[corruptID].KDF, []byte("argon2id")) {
+		t.Error("corrupt blob was rewritten")
+	}
+}
+
+func TestGetLoginParams_ReusesRecentSessionForSameNonce(t *testing.T) {
+	users := newMockUserStore()
+	logins := newMockLoginStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{
+		ID:    userID,
+		Email: "user@example.com",
+		Salt:  []byte("salt"),
+		KDF:   []byte(`{"alg":"argon2id","time":3,"mem_kib":65536,"par":4}`),
+	}
+	tokens := NewTokenService(newMockTokenStore(), []byte("secret"), time.Minute, time.Hour, 0, 0, logger.NewNoop())
+	svc := NewAuth(users, newMockSignupStore(), logins, tokens,
+		model.NewKDFParams(3, 65536, 4), model.NewKDFParams(2, 19456, 1),
+		DefaultPendingSessionTTL, 30*time.Second, 0, false, logger.NewNoop())
+
+	nonce := []byte("client-nonce-0123456789abcdef")
+	first, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: nonce})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	retry, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: nonce})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retry.SessionID != first.SessionID || !bytes.Equal(retry.ServerNonce, first.ServerNonce) {
+		t.Error("retry with the same client nonce did not reuse the pending session")
+	}
+	if len(logins.logins) != 1 {
+		t.Errorf("pending logins = %d, want 1", len(logins.logins))
+	}
+
+	other, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: []byte("different-nonce-0123456789")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other.SessionID == first.SessionID || bytes.Equal(other.ServerNonce, first.ServerNonce) {
+		t.Error("different client nonce must get a fresh session and server nonce")
+	}
+}
+
+func TestGetLoginParams_NoReuseWhenDisabled(t *testing.T) {
+	users := newMockUserStore()
+	logins := newMockLoginStore()
+	userID := uuid.New()
+	users.users[userID] = &model.User{
+		ID:    userID,
+		Email: "user@example.com",
+		KDF:   []byte(`{"alg":"argon2id","time":3,"mem_kib":65536,"par":4}`),
+	}
+	svc := newAuthService(users, newMockSignupStore(), logins)
+
+	nonce := []byte("client-nonce-0123456789abcdef")
+	for i := 0; i < 2; i++ {
+		if _, err := svc.GetLoginParams(context.Background(), LoginParams{Login: "user@example.com", ClientNonce: nonce}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(logins.logins) != 2 {
+		t.Errorf("pending logins = %d, want 2 with reuse disabled", len(logins.logins))
+	}
+}
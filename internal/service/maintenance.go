@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// reencryptJobName keys the re-encryption checkpoint in the checkpoint store.
+const reencryptJobName = "reencrypt-objects"
+
+// reencryptBatchSize is how many keys are listed and copied between
+// checkpoint writes.
+const reencryptBatchSize = 100
+
+// Maintenance runs heavyweight administrative jobs that are not part of the
+// request path.
+type Maintenance struct {
+	storage     model.Storage
+	checkpoints model.CheckpointStore
+	// concurrency bounds parallel object-store operations within one batch,
+	// trading run time against load on the storage backend.
+	concurrency int
+	logger      *logger.Logger
+}
+
+// NewMaintenance creates the maintenance service. Concurrency below one runs
+// sequentially.
+func NewMaintenance(storage model.Storage, checkpoints model.CheckpointStore, concurrency int, l *logger.Logger) *Maintenance {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Maintenance{
+		storage:     storage,
+		checkpoints: checkpoints,
+		concurrency: concurrency,
+		logger:      l,
+	}
+}
+
+// ReEncryptObjects rewrites every stored object in place via server-side copy
+// so it ends up under the bucket's current SSE key after a key rotation. The
+// last processed key is checkpointed after each batch, so an interrupted run
+// resumes where it left off instead of starting over; objects before the
+// checkpoint are already under the new key and are skipped. A completed run
+// clears the checkpoint so the next rotation starts from the beginning.
+// Returns the number of objects copied.
+func (s *Maintenance) ReEncryptObjects(ctx context.Context) (int, error) {
+	afterKey, err := s.checkpoints.Get(ctx, reencryptJobName)
+	if err != nil {
+		return 0, fmt.Errorf("load checkpoint: %w", err)
+	}
+	if afterKey != "" {
+		s.logger.Info("resuming object re-encryption", "after_key", afterKey)
+	}
+
+	copied := 0
+	for {
+		keys, err := s.storage.List(ctx, afterKey, reencryptBatchSize)
+		if err != nil {
+			return copied, fmt.Errorf("list objects: %w", err)
+		}
+		if len(keys) == 0 {
+			break
+		}
+		// Copies within a batch run concurrently; individual failures are
+		// logged per object and collected rather than aborting mid-batch.
+		var failures atomic.Int64
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(s.concurrency)
+		for _, key := range keys {
+			g.Go(func() error {
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+				if err := s.storage.Copy(gctx, key); err != nil {
+					s.logger.Error("failed to copy object", "key", key, "error", err)
+					failures.Add(1)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return copied, err
+		}
+		copied += len(keys) - int(failures.Load())
+		if n := failures.Load(); n > 0 {
+			// The checkpoint stays before this batch, so the retry redoes it;
+			// copying is idempotent, the successful objects are just rewritten.
+			return copied, fmt.Errorf("re-encrypt batch after %q: %d of %d copies failed", afterKey, n, len(keys))
+		}
+		afterKey = keys[len(keys)-1]
+		if err := s.checkpoints.Set(ctx, reencryptJobName, afterKey); err != nil {
+			return copied, fmt.Errorf("save checkpoint: %w", err)
+		}
+		if len(keys) < reencryptBatchSize {
+			break
+		}
+	}
+
+	if err := s.checkpoints.Set(ctx, reencryptJobName, ""); err != nil {
+		return copied, fmt.Errorf("clear checkpoint: %w", err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+type mockCheckpointStore struct {
+	checkpoints map[string]string
+}
+
+func newMockCheckpointStore() *mockCheckpointStore {
+	return &mockCheckpointStore{checkpoints: map[string]string{}}
+}
+
+func (m *mockCheckpointStore) Get(_ context.Context, job string) (string, error) {
+	return m.checkpoints[job], nil
+}
+
+func (m *mockCheckpointStore) Set(_ context.Context, job, checkpoint string) error {
+	m.checkpoints[job] = checkpoint
+	return nil
+}
+
+// copyTrackingStorage records copied keys and can fail on a chosen key.
+type copyTrackingStorage struct {
+	*mockStorage
+	copied  []string
+	failKey string
+}
+
+func (s *copyTrackingStorage) Copy(ctx context.Context, key string) error {
+	if key == s.failKey {
+		return errors.New("copy failed")
+	}
+	s.copied = append(s.copied, key)
+	return s.mockStorage.Copy(ctx, key)
+}
+
+func TestReEncryptObjects_CopiesAllAndClearsCheckpoint(t *testing.T) {
+	storage := &copyTrackingStorage{mockStorage: newMockStorage()}
+	for _, key := range []string{"user1/a", "user1/b", "user2/c"} {
+		storage.objects[key] = []byte("data")
+	}
+	checkpoints := newMockCheckpointStore()
+	maintenance := NewMaintenance(storage, checkpoints, 1, logger.NewNoop())
+
+	copied, err := maintenance.ReEncryptObjects(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if copied != 3 {
+		t.Fatalf("expected 3 copied objects, got %d", copied)
+	}
+	if len(storage.copied) != 3 {
+		t.Fatalf("expected 3 copy calls, got %d", len(storage.copied))
+	}
+	if checkpoint := checkpoints.checkpoints[reencryptJobName]; checkpoint != "" {
+		t.Fatalf("expected checkpoint cleared after completion, got %q", checkpoint)
+	}
+}
+
+func TestReEncryptObjects_ResumesFromCheckpoint(t *testing.T) {
+	storage := &copyTrackingStorage{mockStorage: newMockStorage()}
+	for _, key := range []string{"user1/a", "user1/b", "user2/c"} {
+		storage.objects[key] = []byte("data")
+	}
+	checkpoints := newMockCheckpointStore()
+	checkpoints.checkpoints[reencryptJobName] = "user1/b"
+	maintenance := NewMaintenance(storage, checkpoints, 1, logger.NewNoop())
+
+	copied, err := maintenance.ReEncryptObjects(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if copied != 1 {
+		t.Fatalf("expected 1 copied object after resume, got %d", copied)
+	}
+	if len(storage.copied) != 1 || storage.copied[0] != "user2/c" {
+		t.Fatalf("expected only user2/c copied, got %v", storage.copied)
+	}
+}
+
+func TestReEncryptObjects_CopyFailureStopsRun(t *testing.T) {
+	storage := &copyTrackingStorage{mockStorage: newMockStorage(), failKey: "user2/c"}
+	for _, key := range []string{"user1/a", "user1/b", "user2/c"} {
+		storage.objects[key] = []byte("data")
+	}
+	checkpoints := newMockCheckpointStore()
+	maintenance := NewMaintenance(storage, checkpoints, 1, logger.NewNoop())
+
+	copied, err := maintenance.ReEncryptObjects(context.Background())
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "1 of 3") {
+		t.Fatalf("expected error to report the failed copy count, got %v", err)
+	}
+	if copied != 2 {
+		t.Fatalf("expected 2 objects copied in the failed batch, got %d", copied)
+	}
+	// The checkpoint was not advanced past the failed batch, so a retry
+	// redoes it.
+	if checkpoint := checkpoints.checkpoints[reencryptJobName]; checkpoint != "" {
+		t.Fatalf("expected checkpoint untouched after failed batch, got %q", checkpoint)
+	}
+}
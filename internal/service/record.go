@@ -0,0 +1,1602 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/google/uuid"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/metrics"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// CreateRecordParams carries the client-supplied metadata for a new record.
+type CreateRecordParams struct {
+	RequestID     *uuid.UUID
+	Type          model.RecordType
+	Name          string
+	Description   string
+	EncryptedData []byte
+	EncryptedKey  []byte
+	Alg           string
+	// Compression names the client-side compression applied before
+	// encryption; stored verbatim and echoed back on download.
+	Compression string
+	// Tags are free-form plaintext labels; like Name they are metadata and
+	// not encrypted.
+	Tags []string
+	// Pinned marks a frequently used record; plaintext metadata like Tags.
+	Pinned    bool
+	ChunkSize int64
+	// OriginalCreatedAt preserves the creation time of a restored record.
+	// Values in the future are clamped to the server clock; updated_at is
+	// always server-side so delta sync still picks the row up.
+	OriginalCreatedAt *time.Time
+	// DryRun runs the validation and ownership checks only: nothing is
+	// persisted and no object is uploaded.
+	DryRun bool
+}
+
+// ChunkReceiver yields consecutive payload chunks from a client upload stream.
+// It returns io.EOF when the stream is exhausted.
+type ChunkReceiver interface {
+	Recv() ([]byte, error)
+}
+
+// ChunkSender delivers a record and its payload chunks to a client download
+// stream.
+type ChunkSender interface {
+	SendMetadata(record *model.Record) error
+	SendChunk(data []byte, last bool) error
+}
+
+// EventSender delivers record change events to a client watch stream.
+type EventSender interface {
+	SendEvent(event model.RecordEvent) error
+}
+
+// ExportSender delivers a full export stream: each record's metadata and
+// chunks, plus error markers for records whose payload cannot be read.
+type ExportSender interface {
+	ChunkSender
+	SendRecordError(recordID uuid.UUID, message string) error
+}
+
+// RecordLimits bounds the client-supplied metadata fields.
+type RecordLimits struct {
+	MaxNameLength        int
+	MaxDescriptionLength int
+	// MaxChunkSize caps the per-download read buffer. Records stored with a
+	// larger EncryptedChunkSize are re-chunked on the wire; zero disables the
+	// clamp.
+	MaxChunkSize int64
+	// DefaultChunkSize applies when binary metadata omits ChunkSize; zero
+	// keeps the omission an error. MinChunkSize rejects pathologically tiny
+	// chunks and MaxUploadChunkSize clamps oversized ones; zero disables the
+	// respective bound.
+	DefaultChunkSize   int64
+	MinChunkSize       int64
+	MaxUploadChunkSize int64
+	// MaxInlineBinarySize is the cutoff up to which GetRecord fetches a
+	// binary payload and inlines it in the response; zero disables inlining.
+	MaxInlineBinarySize int64
+	// MaxInlineStreamPayload is the promotion threshold for streamed uploads
+	// of non-binary types. A reassembled payload at or below it lands in the
+	// inline EncryptedData column like a unary create; a larger one is
+	// promoted to the object storage and served like a binary payload from
+	// then on. Zero keeps the streaming upload path binary-only.
+	MaxInlineStreamPayload int64
+	// MaxUnaryResponseSize fails GetRecord with FailedPrecondition when the
+	// inline EncryptedData would exceed it, pointing the client at the
+	// streaming path before the transport rejects the send. Keep it at or
+	// below the gRPC max send size; zero disables the check.
+	MaxUnaryResponseSize int64
+	// MaxPayloadSize caps the inline EncryptedData per record type on the
+	// unary create path. Types without an entry (or with zero) are uncapped.
+	MaxPayloadSize map[model.RecordType]int
+	// AllowedAlgs is the allowlist of encryption algorithm identifiers
+	// accepted in record metadata, so a client bug cannot persist records
+	// the clients cannot decrypt. Empty disables the check.
+	AllowedAlgs []string
+	// MaxEncryptedKeySize caps the encrypted data key envelope; zero
+	// disables the check.
+	MaxEncryptedKeySize int
+	// UploadIdleTimeout aborts a streaming upload when no chunk arrives
+	// within the window, so a stalled client cannot hold the pipe and a
+	// partial upload open indefinitely. Zero disables the timeout.
+	UploadIdleTimeout time.Duration
+	// MaxTagsPerRecord and MaxTagLength bound the free-form tags; zero
+	// disables the respective check.
+	MaxTagsPerRecord int
+	MaxTagLength     int
+	// MaxRecordsPerUser caps how many live records a user may own; zero
+	// means unlimited. Soft-deleted records do not count.
+	MaxRecordsPerUser int
+	// JobConcurrency bounds parallel object-store operations in background
+	// jobs such as the purge; zero or one runs them sequentially.
+	JobConcurrency int
+	// SanitizeDescriptions strips control characters and HTML-escapes
+	// descriptions on write, for deployments whose web frontends render them
+	// without escaping. Off by default: the server should not silently alter
+	// client data. Descriptions must be valid UTF-8 either way.
+	SanitizeDescriptions bool
+	// MaxSyncLookback caps how far back a delta cursor may reach. A client
+	// whose updatedAfter is older fails with the full-resync error instead of
+	// triggering an unbounded history scan. Zero disables the cap.
+	MaxSyncLookback time.Duration
+	// TombstoneAckWindow is the safety window for early tombstone purging: a
+	// tombstone younger than the window is never purged ahead of the retention
+	// cutoff, however many devices have acknowledged it, so a device that has
+	// not synced recently still finds it on the next delta. Zero disables
+	// early purging; acknowledgments are still recorded.
+	TombstoneAckWindow time.Duration
+	// PresignedUploads lets clients replace a binary record's contents by
+	// uploading directly to the object store through a presigned URL; off by
+	// default because it requires the object store to be reachable by
+	// clients. PresignedPutExpiry bounds how long an issued URL stays valid;
+	// zero falls back to defaultPresignedPutExpiry.
+	PresignedUploads   bool
+	PresignedPutExpiry time.Duration
+	// VerifyUploads stats every uploaded object before the database row is
+	// committed, confirming it is retrievable and its size matches the bytes
+	// written; on mismatch the object is removed and the create fails. Off by
+	// default because it costs a round trip per upload.
+	VerifyUploads bool
+}
+
+// maxStoredChunkSize is the hard ceiling on a record's EncryptedChunkSize;
+// anything above it is rejected at create time and refused on download.
+const maxStoredChunkSize = 64 << 20
+
+// Record implements the record business logic on top of the metadata store and
+// the object storage.
+type Record struct {
+	recordStore model.RecordStore
+	userStore   model.UserStore
+	eventStore  model.EventStore
+	// ackStore holds per-device tombstone acknowledgments; nil disables
+	// acknowledgment handling altogether.
+	ackStore model.TombstoneAckStore
+	storage  model.Storage
+	limits   RecordLimits
+	// keyPrefix namespaces object keys, keeping environments apart when they
+	// share a bucket. Empty means keys sit directly under the bucket root.
+	keyPrefix string
+	// exposeForbidden reports ownership mismatches as PermissionDenied
+	// instead of masking them as NotFound. Off by default so record
+	// existence is not leaked; meant for trusted internal deployments.
+	exposeForbidden bool
+	// metrics receives the business-level counters; nil disables them.
+	metrics *metrics.Metrics
+	logger  *logger.Logger
+}
+
+// NewRecord creates the record service.
+func NewRecord(
+	recordStore model.RecordStore,
+	userStore model.UserStore,
+	eventStore model.EventStore,
+	ackStore model.TombstoneAckStore,
+	storage model.Storage,
+	limits RecordLimits,
+	keyPrefix string,
+	exposeForbidden bool,
+	m *metrics.Metrics,
+	l *logger.Logger,
+) *Record {
+	if keyPrefix != "" && !strings.HasSuffix(keyPrefix, "/") {
+		keyPrefix += "/"
+	}
+	return &Record{
+		recordStore:     recordStore,
+		userStore:       userStore,
+		eventStore:      eventStore,
+		ackStore:        ackStore,
+		storage:         storage,
+		limits:          limits,
+		keyPrefix:       keyPrefix,
+		exposeForbidden: exposeForbidden,
+		metrics:         m,
+		logger:          l,
+	}
+}
+
+// CreateRecord creates a non-binary record with an inline encrypted payload.
+// The caller is already authenticated, so owner existence is left to the
+// owner_id foreign key instead of an extra user lookup on this hot path; the
+// repository translates a violation into a user-not-found error. The bool
+// reports an idempotent replay: the returned record already existed under the
+// same request_id.
+func (s *Record) CreateRecord(ctx context.Context, userID uuid.UUID, params CreateRecordParams) (*model.Record, bool, error) {
+	if err := s.validateMetadata(params); err != nil {
+		return nil, false, err
+	}
+	params.Description = s.sanitizeDescription(params.Description)
+	if params.Type == model.RecordTypeBinary {
+		return nil, false, apiErrors.NewErrInvalidMetadata("binary records must be uploaded via stream")
+	}
+	if max := s.limits.MaxPayloadSize[params.Type]; max > 0 && len(params.EncryptedData) > max {
+		return nil, false, apiErrors.NewErrInvalidMetadata(fmt.Sprintf("%s payload exceeds %d bytes", params.Type, max))
+	}
+	if err := s.checkRecordQuota(ctx, userID); err != nil {
+		return nil, false, err
+	}
+	if params.DryRun {
+		return nil, false, nil
+	}
+
+	record := &model.Record{
+		ID:            uuid.New(),
+		OwnerID:       userID,
+		RequestID:     params.RequestID,
+		Type:          params.Type,
+		Name:          params.Name,
+		Description:   params.Description,
+		EncryptedData: params.EncryptedData,
+		EncryptedKey:  params.EncryptedKey,
+		Alg:           params.Alg,
+		Compression:   params.Compression,
+		Tags:          params.Tags,
+		Pinned:        params.Pinned,
+		CreatedAt:     restoredCreatedAt(params),
+	}
+	created, inserted, err := s.recordStore.Create(ctx, record)
+	if err != nil {
+		return nil, false, fmt.Errorf("create record: %w", err)
+	}
+	if !inserted {
+		s.metrics.IncIdempotentCreate()
+		s.logger.Info("idempotent create replayed", "record_id", created.ID, "request_id", params.RequestID)
+	}
+	return created, !inserted, nil
+}
+
+// CreateRecordStream creates a record whose payload is received in chunks.
+// Binary payloads stream into the object storage as before. The other types
+// are accepted too, for encrypted notes that have outgrown the unary message
+// limit: their chunks are reassembled into the inline EncryptedData column up
+// to MaxInlineStreamPayload, and promoted to the object storage above it. The
+// bool reports an idempotent replay, as in CreateRecord.
+func (s *Record) CreateRecordStream(ctx context.Context, userID uuid.UUID, params CreateRecordParams, chunks ChunkReceiver) (*model.Record, bool, error) {
+	if err := s.validateMetadata(params); err != nil {
+		return nil, false, err
+	}
+	params.Description = s.sanitizeDescription(params.Description)
+	if params.Type != model.RecordTypeBinary && s.limits.MaxInlineStreamPayload <= 0 {
+		return nil, false, apiErrors.NewErrInvalidMetadata("streaming uploads are only supported for binary records")
+	}
+	chunkSize, err := s.effectiveChunkSize(params.ChunkSize)
+	if err != nil {
+		if params.Type == model.RecordTypeBinary || params.ChunkSize > 0 {
+			return nil, false, err
+		}
+		// Non-binary uploads may omit the chunk size: the payload is a single
+		// ciphertext, and if it ends up promoted the stored chunk size is a
+		// transport detail chosen at promotion time.
+		chunkSize = 0
+	}
+	if err := s.checkRecordQuota(ctx, userID); err != nil {
+		return nil, false, err
+	}
+	if params.DryRun {
+		return nil, false, nil
+	}
+
+	record := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		RequestID:          params.RequestID,
+		Type:               params.Type,
+		Name:               params.Name,
+		Description:        params.Description,
+		EncryptedKey:       params.EncryptedKey,
+		Alg:                params.Alg,
+		Compression:        params.Compression,
+		Tags:               params.Tags,
+		Pinned:             params.Pinned,
+		EncryptedChunkSize: chunkSize,
+		CreatedAt:          restoredCreatedAt(params),
+	}
+
+	pr, pw := io.Pipe()
+	recvDone := make(chan struct{})
+	// Tear the pipe down on client disconnect so neither the reader goroutine
+	// nor a blocked Upload outlives the request.
+	go func() {
+		select {
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+		case <-recvDone:
+		}
+	}()
+	go func() {
+		defer close(recvDone)
+
+		type recvResult struct {
+			chunk []byte
+			err   error
+		}
+		recvCh := make(chan recvResult)
+		go func() {
+			for {
+				chunk, err := chunks.Recv()
+				select {
+				case recvCh <- recvResult{chunk: chunk, err: err}:
+				case <-recvDone:
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		// The idle timer guards against a client that opens the stream and
+		// then stalls, holding the pipe and a partial upload open.
+		var timeoutCh <-chan time.Time
+		var timer *time.Timer
+		if idle := s.limits.UploadIdleTimeout; idle > 0 {
+			timer = time.NewTimer(idle)
+			defer timer.Stop()
+			timeoutCh = timer.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				pw.CloseWithError(ctx.Err())
+				return
+			case <-timeoutCh:
+				pw.CloseWithError(apiErrors.NewErrStreamIdleTimeout(s.limits.UploadIdleTimeout))
+				return
+			case result := <-recvCh:
+				// gRPC allows a final Recv to return data together with
+				// io.EOF, so the trailing chunk is written before closing
+				// the pipe.
+				if len(result.chunk) > 0 {
+					if _, werr := pw.Write(result.chunk); werr != nil {
+						return
+					}
+				}
+				if result.err == io.EOF {
+					pw.Close()
+					return
+				}
+				if result.err != nil {
+					pw.CloseWithError(result.err)
+					return
+				}
+				if timer != nil {
+					if !timer.Stop() {
+						<-timer.C
+					}
+					timer.Reset(s.limits.UploadIdleTimeout)
+				}
+			}
+		}
+	}()
+
+	if params.Type == model.RecordTypeBinary {
+		return s.saveRecord(ctx, record, pr, -1)
+	}
+	return s.saveStreamedInline(ctx, record, pr)
+}
+
+// defaultInlineStreamChunk is the transport chunk size used for non-binary
+// payloads when neither the client nor the configuration chose one. Non-binary
+// payloads are a single ciphertext, so the value only shapes wire framing.
+const defaultInlineStreamChunk = 256 << 10
+
+// saveStreamedInline reassembles a streamed non-binary payload. Up to
+// MaxInlineStreamPayload the payload is stored in the inline EncryptedData
+// column, exactly as a unary create would; anything larger is promoted to the
+// object storage so an oversized note cannot bloat the metadata table.
+func (s *Record) saveStreamedInline(ctx context.Context, record *model.Record, payload io.Reader) (*model.Record, bool, error) {
+	inlineCap := s.limits.MaxInlineStreamPayload
+	head, err := io.ReadAll(io.LimitReader(payload, inlineCap+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("receive payload: %w", err)
+	}
+	if int64(len(head)) <= inlineCap {
+		record.EncryptedData = head
+		record.EncryptedChunkSize = 0
+		created, inserted, err := s.recordStore.Create(ctx, record)
+		if err != nil {
+			return nil, false, fmt.Errorf("create record: %w", err)
+		}
+		if !inserted {
+			s.metrics.IncIdempotentCreate()
+			s.logger.Info("idempotent create replayed", "record_id", created.ID, "request_id", record.RequestID)
+		}
+		return created, !inserted, nil
+	}
+	if record.EncryptedChunkSize <= 0 {
+		record.EncryptedChunkSize = defaultInlineStreamChunk
+	}
+	return s.saveRecord(ctx, record, io.MultiReader(bytes.NewReader(head), payload), -1)
+}
+
+// GetRecord returns a record owned by the user. Missing and foreign records
+// are both reported as not found. With includeDeleted a soft-deleted record
+// is returned with DeletedAt populated, so a sync client that just learned of
+// a deletion can confirm it instead of being unable to tell deleted from
+// never-existed. Object-backed payloads — binaries, and non-binary records
+// promoted past MaxInlineStreamPayload — are fetched and inlined up to
+// MaxInlineBinarySize so small files don't force a streaming call; larger
+// ones yield FailedPrecondition pointing the client at GetRecordStream.
+func (s *Record) GetRecord(ctx context.Context, userID, recordID uuid.UUID, includeDeleted bool) (*model.Record, error) {
+	var record *model.Record
+	var err error
+	if includeDeleted {
+		record, err = s.recordStore.GetByIDIncludingDeleted(ctx, recordID)
+		if err != nil {
+			err = fmt.Errorf("get record: %w", err)
+		}
+		record, err = s.checkOwned(record, err, userID)
+	} else {
+		record, err = s.getOwnedRecord(ctx, userID, recordID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A tombstone's object may already be purged; the client only needs the
+	// metadata with DeletedAt to reconcile, so skip payload handling.
+	if record.DeletedAt != nil {
+		return record, nil
+	}
+	if record.S3Key == "" || s.limits.MaxInlineBinarySize <= 0 {
+		// A note edited many times can outgrow the gRPC send limit; fail
+		// with a clear pointer at the streaming path instead of letting the
+		// transport produce an opaque send error.
+		if max := s.limits.MaxUnaryResponseSize; max > 0 && int64(len(record.EncryptedData)) > max {
+			return nil, apiErrors.NewErrPayloadTooLargeForUnary(int64(len(record.EncryptedData)), max)
+		}
+		return record, nil
+	}
+
+	object, err := s.storage.Download(ctx, record.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("download object: %w", err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(io.LimitReader(object, s.limits.MaxInlineBinarySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read object: %w", err)
+	}
+	if int64(len(data)) > s.limits.MaxInlineBinarySize {
+		return nil, apiErrors.NewErrBinaryTooLargeForInline(s.limits.MaxInlineBinarySize)
+	}
+
+	inlined := *record
+	inlined.EncryptedData = data
+	return &inlined, nil
+}
+
+// getOwnedRecord fetches a live record and enforces ownership without
+// touching the object storage; internal callers that only need the row use
+// this. Every caller mutates the record next, so the fetch insists on the
+// primary database: a lagging replica must not hide a just-created record
+// from its own mutation.
+func (s *Record) getOwnedRecord(ctx context.Context, userID, recordID uuid.UUID) (*model.Record, error) {
+	record, err := s.recordStore.GetByID(model.WithPrimaryReads(ctx), recordID)
+	if err != nil {
+		return nil, fmt.Errorf("get record: %w", err)
+	}
+	return s.checkOwned(record, nil, userID)
+}
+
+// checkOwned maps a fetch result to the caller-facing outcome: missing and
+// foreign records are both not found, unless the deployment exposes
+// ownership errors.
+func (s *Record) checkOwned(record *model.Record, err error, userID uuid.UUID) (*model.Record, error) {
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, apiErrors.NewErrRecordNotFound()
+	}
+	if record.OwnerID != userID {
+		if s.exposeForbidden {
+			return nil, apiErrors.NewErrForbidden()
+		}
+		return nil, apiErrors.NewErrRecordNotFound()
+	}
+	return record, nil
+}
+
+// maxBatchGetIDs caps how many record IDs one GetByIDs call may request.
+const maxBatchGetIDs = 100
+
+// GetByIDs returns the requested records that exist and belong to the user.
+// IDs of missing or foreign records are silently dropped rather than erroring,
+// so a sync client can pass a delta's ID set as-is.
+func (s *Record) GetByIDs(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]model.Record, error) {
+	if len(ids) > maxBatchGetIDs {
+		return nil, apiErrors.NewErrBatchTooLarge(maxBatchGetIDs)
+	}
+	rows, err := s.recordStore.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get records: %w", err)
+	}
+	var records []model.Record
+	for _, r := range rows {
+		if r.OwnerID != userID {
+			continue
+		}
+		records = append(records, *r)
+	}
+	return records, nil
+}
+
+// StreamRecordToClient sends a record's metadata and payload chunks to the
+// client. Binary records are chunked at the size they were encrypted with;
+// non-binary records — inline or promoted — carry a single ciphertext, so
+// their chunking is purely a transport detail and the client reassembles the
+// whole payload before decrypting.
+func (s *Record) StreamRecordToClient(ctx context.Context, userID, recordID uuid.UUID, sender ChunkSender) error {
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return err
+	}
+	if record.S3Key == "" {
+		// An inline payload small enough for the unary path still streams
+		// fine: notes rejected by the unary response cap land here.
+		inline := *record
+		inline.EncryptedChunkSize = s.limits.DefaultChunkSize
+		if inline.EncryptedChunkSize <= 0 {
+			inline.EncryptedChunkSize = defaultInlineStreamChunk
+		}
+		return s.sendRecordPayload(ctx, &inline, bytes.NewReader(record.EncryptedData), sender)
+	}
+	// An unknown or empty alg means the row is corrupt or predates the
+	// allowlist; fail before paying for the object download, because the
+	// client cannot decrypt the payload anyway.
+	if record.Alg == "" {
+		return fmt.Errorf("record %s has empty alg", record.ID)
+	}
+	if !s.algAllowed(record.Alg) {
+		return fmt.Errorf("record %s has unsupported alg %q", record.ID, record.Alg)
+	}
+
+	object, err := s.storage.Download(ctx, record.S3Key)
+	if err != nil {
+		return fmt.Errorf("download object: %w", err)
+	}
+	defer object.Close()
+
+	return s.sendRecordPayload(ctx, record, object, sender)
+}
+
+// chunkBufPool reuses download buffers across streams to keep many concurrent
+// downloads from each allocating a fresh chunk-sized slice.
+var chunkBufPool = sync.Pool{
+	New: func() any { return []byte(nil) },
+}
+
+// sendRecordPayload sends a record's metadata and its payload chunks. Reads
+// are clamped to the configured max chunk size; wire chunking is a transport
+// detail and need not match the encryption chunking, because the chunks form
+// a contiguous byte stream and the metadata carries EncryptedChunkSize, which
+// the client splits the stream by to recover decryption boundaries.
+func (s *Record) sendRecordPayload(ctx context.Context, record *model.Record, object io.Reader, sender ChunkSender) error {
+	chunkSize := record.EncryptedChunkSize
+	if chunkSize <= 0 || chunkSize > maxStoredChunkSize {
+		return fmt.Errorf("record has invalid chunk size %d", chunkSize)
+	}
+	if s.limits.MaxChunkSize > 0 && chunkSize > s.limits.MaxChunkSize {
+		chunkSize = s.limits.MaxChunkSize
+	}
+
+	if err := sender.SendMetadata(record); err != nil {
+		return fmt.Errorf("send metadata: %w", err)
+	}
+
+	buf := chunkBufPool.Get().([]byte)
+	if int64(cap(buf)) < chunkSize {
+		buf = make([]byte, chunkSize)
+	}
+	buf = buf[:chunkSize]
+	defer chunkBufPool.Put(buf)
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(object, buf)
+		if errors.Is(err, io.EOF) {
+			if err := verifyPayloadSize(record, total); err != nil {
+				return err
+			}
+			return sender.SendChunk(nil, true)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if err := verifyPayloadSize(record, total+int64(n)); err != nil {
+				return err
+			}
+			return sender.SendChunk(buf[:n], true)
+		}
+		if err != nil {
+			return fmt.Errorf("read object: %w", err)
+		}
+		total += int64(n)
+		if err := sender.SendChunk(buf[:n], false); err != nil {
+			return fmt.Errorf("send chunk: %w", err)
+		}
+	}
+}
+
+// verifyPayloadSize compares the bytes actually read from the object store
+// with the size recorded at upload, catching truncated or corrupt objects
+// instead of silently delivering them. A zero stored size means the record
+// predates size tracking and is exempt.
+func verifyPayloadSize(record *model.Record, read int64) error {
+	if record.FileSize > 0 && read != record.FileSize {
+		return fmt.Errorf("object %s truncated or corrupt: read %d bytes, stored size is %d", record.S3Key, read, record.FileSize)
+	}
+	return nil
+}
+
+// countingReader counts the bytes drained from the wrapped reader, measuring
+// the size of a streamed upload.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportRecords streams every record of the user in one response: metadata
+// followed by payload chunks per record, with the last-chunk flag delimiting
+// records. Non-binary payloads are sent as a single chunk. A record whose
+// object cannot be downloaded yields an error marker instead of aborting the
+// export; errors sending to the client do abort.
+func (s *Record) ExportRecords(ctx context.Context, userID uuid.UUID, sender ExportSender) error {
+	rows, err := s.recordStore.GetByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get records: %w", err)
+	}
+
+	for _, record := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if record.S3Key == "" {
+			if err := sender.SendMetadata(record); err != nil {
+				return fmt.Errorf("send metadata: %w", err)
+			}
+			if err := sender.SendChunk(record.EncryptedData, true); err != nil {
+				return fmt.Errorf("send chunk: %w", err)
+			}
+			continue
+		}
+
+		object, err := s.storage.Download(ctx, record.S3Key)
+		if err != nil {
+			s.logger.Error("export: failed to download object", "key", record.S3Key, "error", err)
+			if sendErr := sender.SendRecordError(record.ID, "payload unavailable"); sendErr != nil {
+				return fmt.Errorf("send record error: %w", sendErr)
+			}
+			continue
+		}
+		err = s.sendRecordPayload(ctx, record, object, sender)
+		object.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByUserID returns all live records of the user.
+func (s *Record) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Record, error) {
+	rows, err := s.recordStore.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get records: %w", err)
+	}
+	// Always non-nil, so an empty listing serializes as an empty array rather
+	// than null, which some clients cannot parse.
+	records := make([]model.Record, 0, len(rows))
+	for _, r := range rows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		records = append(records, *r)
+	}
+	return records, nil
+}
+
+// ListRecordIDs returns the IDs of the user's live records. Cache-holding
+// clients use it as a cheap integrity index to detect local orphans without
+// paying for the full metadata of a large account.
+func (s *Record) ListRecordIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := s.recordStore.GetIDsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get record ids: %w", err)
+	}
+	if ids == nil {
+		ids = []uuid.UUID{}
+	}
+	return ids, nil
+}
+
+// GetByTag returns the user's live records carrying the given tag.
+func (s *Record) GetByTag(ctx context.Context, userID uuid.UUID, tag string) ([]model.Record, error) {
+	if tag == "" {
+		return nil, apiErrors.NewErrInvalidMetadata("tag is required")
+	}
+	rows, err := s.recordStore.GetByUserIDAndTag(ctx, userID, tag)
+	if err != nil {
+		return nil, fmt.Errorf("get records by tag: %w", err)
+	}
+	records := make([]model.Record, 0, len(rows))
+	for _, r := range rows {
+		records = append(records, *r)
+	}
+	return records, nil
+}
+
+// GetPinnedRecords returns the user's pinned records.
+func (s *Record) GetPinnedRecords(ctx context.Context, userID uuid.UUID) ([]model.Record, error) {
+	rows, err := s.recordStore.GetPinnedByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get pinned records: %w", err)
+	}
+	records := make([]model.Record, 0, len(rows))
+	for _, r := range rows {
+		records = append(records, *r)
+	}
+	return records, nil
+}
+
+// SetRecordPinned sets a record's pinned flag after an ownership check.
+func (s *Record) SetRecordPinned(ctx context.Context, userID, recordID uuid.UUID, pinned bool) error {
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return err
+	}
+	if err := s.recordStore.SetPinned(ctx, record.ID, pinned, time.Now()); err != nil {
+		return fmt.Errorf("set pinned: %w", err)
+	}
+	return nil
+}
+
+// ChangeRecordType converts a record to another type after an ownership
+// check. Conversions to or from binary are rejected: a binary record's
+// payload lives in the object storage while the other types carry it inline,
+// so the type flag alone cannot move it. The change bumps updated_at, so
+// delta sync and type-filtered listings pick it up.
+func (s *Record) ChangeRecordType(ctx context.Context, userID, recordID uuid.UUID, newType model.RecordType) error {
+	if !newType.Valid() {
+		return apiErrors.NewErrInvalidRecordType(string(newType))
+	}
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return err
+	}
+	if record.Type == newType {
+		return nil
+	}
+	if record.Type == model.RecordTypeBinary || newType == model.RecordTypeBinary {
+		return apiErrors.NewErrTypeChangeDenied(string(record.Type), string(newType))
+	}
+	if err := s.recordStore.SetType(ctx, record.ID, newType, time.Now()); err != nil {
+		return fmt.Errorf("set type: %w", err)
+	}
+	return nil
+}
+
+// SetRecordTags replaces a record's tags after an ownership check.
+func (s *Record) SetRecordTags(ctx context.Context, userID, recordID uuid.UUID, tags []string) error {
+	if err := s.validateTags(tags); err != nil {
+		return err
+	}
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return err
+	}
+	if err := s.recordStore.SetTags(ctx, record.ID, tags, time.Now()); err != nil {
+		return fmt.Errorf("set tags: %w", err)
+	}
+	return nil
+}
+
+// ListRecordsDelta returns records updated after the given time and, when
+// requested, tombstones of records deleted after it. Tombstones the client
+// has already acknowledged — deleted at or before ackedBefore — are excluded;
+// the zero time excludes nothing. A cursor older than MaxSyncLookback fails
+// with the full-resync error so the client drops it and syncs from scratch.
+func (s *Record) ListRecordsDelta(ctx context.Context, userID uuid.UUID, updatedAfter time.Time, includeDeleted bool, ackedBefore time.Time) ([]model.Record, []model.Tombstone, error) {
+	// An epoch-or-earlier cursor is the handler's "everything" sentinel, not
+	// a stale delta cursor, so the lookback cap does not apply to it.
+	if window := s.limits.MaxSyncLookback; window > 0 && updatedAfter.Unix() > 0 && time.Since(updatedAfter) > window {
+		return nil, nil, apiErrors.NewErrFullResyncRequired(window)
+	}
+	rows, err := s.recordStore.GetUpdatedAfter(ctx, userID, updatedAfter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get updated records: %w", err)
+	}
+	records := make([]model.Record, 0, len(rows))
+	for _, r := range rows {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		records = append(records, *r)
+	}
+
+	tombstones := make([]model.Tombstone, 0)
+	if includeDeleted {
+		// The client may have disconnected while the first query ran; do not
+		// pay for the tombstone scan on its behalf.
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		deletedAfter := updatedAfter
+		if ackedBefore.After(deletedAfter) {
+			deletedAfter = ackedBefore
+		}
+		deleted, err := s.recordStore.GetDeletedAfter(ctx, userID, deletedAfter)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get deleted records: %w", err)
+		}
+		tombstones = append(tombstones, deleted...)
+	}
+	return records, tombstones, nil
+}
+
+// AcknowledgeTombstones records that the device has applied every tombstone
+// deleted before ackedBefore, then hard-deletes the user's fully propagated
+// tombstones. The purge cutoff is the earliest acknowledgment across the
+// user's devices, additionally clamped to TombstoneAckWindow ago: a device
+// that never acknowledges is invisible here, so the window is what protects
+// one that existed but has not synced recently. A device arriving after the
+// window without having acknowledged must run a full sync, which it would do
+// anyway with no local state to delta from.
+func (s *Record) AcknowledgeTombstones(ctx context.Context, userID uuid.UUID, deviceID string, ackedBefore time.Time) error {
+	if s.ackStore == nil {
+		return nil
+	}
+	now := time.Now()
+	// A clock-skewed client cannot acknowledge tombstones from the future.
+	if ackedBefore.After(now) {
+		ackedBefore = now
+	}
+	if err := s.ackStore.Upsert(ctx, userID, deviceID, ackedBefore, now); err != nil {
+		return fmt.Errorf("store tombstone ack: %w", err)
+	}
+	window := s.limits.TombstoneAckWindow
+	if window <= 0 {
+		return nil
+	}
+	mark, err := s.ackStore.HighWaterMark(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get ack high-water mark: %w", err)
+	}
+	if mark.IsZero() {
+		return nil
+	}
+	cutoff := now.Add(-window)
+	if mark.Before(cutoff) {
+		cutoff = mark
+	}
+	if _, err := s.purgeBatches(ctx, func(ctx context.Context, limit int) ([]*model.Record, error) {
+		return s.recordStore.ListDeletedBeforeForUser(ctx, userID, cutoff, limit)
+	}); err != nil {
+		return fmt.Errorf("purge acked tombstones: %w", err)
+	}
+	return nil
+}
+
+// TransferRecord reassigns a record owned by fromUserID to toUserID. This is a
+// plain ownership move, not sharing: the sender loses access. The payload's
+// object key embeds the old owner's ID but stays valid, so nothing is copied
+// in the object storage.
+func (s *Record) TransferRecord(ctx context.Context, fromUserID, recordID, toUserID uuid.UUID) error {
+	record, err := s.getOwnedRecord(ctx, fromUserID, recordID)
+	if err != nil {
+		return err
+	}
+	target, err := s.userStore.GetByID(ctx, toUserID)
+	if err != nil {
+		return fmt.Errorf("get target user: %w", err)
+	}
+	if target == nil {
+		return apiErrors.NewErrUserNotFound()
+	}
+	if err := s.recordStore.TransferOwner(ctx, record.ID, toUserID, time.Now()); err != nil {
+		return fmt.Errorf("transfer record: %w", err)
+	}
+	return nil
+}
+
+// watchPollInterval is how often a watch stream polls the outbox for new
+// events; watchBatchSize caps one poll's result.
+const (
+	watchPollInterval = 2 * time.Second
+	watchBatchSize    = 100
+)
+
+// WatchRecords tails the record-event outbox for the user, sending every event
+// with a sequence number greater than afterSeq and then polling for new ones
+// until the context is cancelled. Because the cursor addresses the durable
+// outbox, events written while the client was disconnected are replayed when
+// it reconnects with its last seen sequence number.
+func (s *Record) WatchRecords(ctx context.Context, userID uuid.UUID, afterSeq int64, sender EventSender) error {
+	cursor := afterSeq
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			events, err := s.eventStore.ListAfter(ctx, userID, cursor, watchBatchSize)
+			if err != nil {
+				return fmt.Errorf("list record events: %w", err)
+			}
+			for _, event := range events {
+				if err := sender.SendEvent(event); err != nil {
+					return fmt.Errorf("send record event: %w", err)
+				}
+				cursor = event.Seq
+			}
+			if len(events) < watchBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeleteRecord soft-deletes a record owned by the user. A non-zero
+// expectedVersion must match the stored version, giving concurrent editors a
+// clean conflict signal; zero skips the check. The stored object of an
+// object-backed record is retained for the soft-delete retention window and
+// removed by the purge job, so the delete stays undoable; purge forces
+// immediate object removal for compliance deletions.
+func (s *Record) DeleteRecord(ctx context.Context, userID, recordID uuid.UUID, expectedVersion int64, purge bool) error {
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return err
+	}
+	if expectedVersion != 0 && record.Version != expectedVersion {
+		return apiErrors.NewErrVersionConflict(expectedVersion, record.Version)
+	}
+
+	if err := s.recordStore.Delete(ctx, record.ID, time.Now()); err != nil {
+		return fmt.Errorf("delete record: %w", err)
+	}
+	if purge && record.S3Key != "" {
+		if err := s.storage.Delete(ctx, record.S3Key); err != nil {
+			s.logger.Error("failed to purge object", "key", record.S3Key, "error", err)
+		}
+	}
+	return nil
+}
+
+// maxBatchDeleteIDs caps how many record IDs one BatchDeleteRecords call may
+// request.
+const maxBatchDeleteIDs = 100
+
+// BatchDeleteStatus is the per-record outcome of a batch delete.
+type BatchDeleteStatus string
+
+const (
+	BatchDeleteStatusDeleted  BatchDeleteStatus = "deleted"
+	BatchDeleteStatusNotFound BatchDeleteStatus = "not_found"
+	BatchDeleteStatusNotOwned BatchDeleteStatus = "not_owned"
+)
+
+// BatchDeleteResult reports what happened to one requested ID.
+type BatchDeleteResult struct {
+	RecordID uuid.UUID
+	Status   BatchDeleteStatus
+}
+
+// BatchDeleteRecords soft-deletes the user's records in a single database
+// transaction, returning a result per requested ID in request order. Stored
+// objects are left to the purge job so the batch stays fast and transactional
+// on the database side. Foreign records are reported as
+// not_found unless the service is configured to expose ownership mismatches.
+func (s *Record) BatchDeleteRecords(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) ([]BatchDeleteResult, error) {
+	if len(ids) > maxBatchDeleteIDs {
+		return nil, apiErrors.NewErrBatchTooLarge(maxBatchDeleteIDs)
+	}
+
+	records, err := s.recordStore.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get records: %w", err)
+	}
+	byID := make(map[uuid.UUID]*model.Record, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+
+	results := make([]BatchDeleteResult, 0, len(ids))
+	toDelete := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		record, ok := byID[id]
+		switch {
+		case !ok:
+			results = append(results, BatchDeleteResult{RecordID: id, Status: BatchDeleteStatusNotFound})
+		case record.OwnerID != userID:
+			status := BatchDeleteStatusNotFound
+			if s.exposeForbidden {
+				status = BatchDeleteStatusNotOwned
+			}
+			results = append(results, BatchDeleteResult{RecordID: id, Status: status})
+		default:
+			toDelete = append(toDelete, id)
+			results = append(results, BatchDeleteResult{RecordID: id, Status: BatchDeleteStatusDeleted})
+		}
+	}
+
+	if err := s.recordStore.DeleteMany(ctx, toDelete, time.Now()); err != nil {
+		return nil, fmt.Errorf("delete records: %w", err)
+	}
+	return results, nil
+}
+
+// ImportStrategy selects how ImportRecords resolves a conflict with an
+// existing server record carrying the same request ID.
+type ImportStrategy string
+
+const (
+	ImportServerWins ImportStrategy = "server_wins"
+	ImportClientWins ImportStrategy = "client_wins"
+	ImportNewestWins ImportStrategy = "newest_wins"
+)
+
+// ImportStatus is the per-record outcome of an import.
+type ImportStatus string
+
+const (
+	ImportStatusCreated ImportStatus = "created"
+	ImportStatusUpdated ImportStatus = "updated"
+	ImportStatusSkipped ImportStatus = "skipped"
+)
+
+// ImportRecordItem is one client-side record offered to ImportRecords.
+type ImportRecordItem struct {
+	CreateRecordParams
+	// UpdatedAt is the client-side last modification time, compared against
+	// the server row under the newest-wins strategy.
+	UpdatedAt time.Time
+}
+
+// ImportResult reports what happened to one imported record, in item order.
+type ImportResult struct {
+	RecordID uuid.UUID
+	Status   ImportStatus
+}
+
+// maxImportRecords caps how many records one ImportRecords call may carry.
+const maxImportRecords = 100
+
+// ImportRecords merges a batch of client-side records into the user's set,
+// matching existing rows by request ID through the same idempotency index the
+// unary create relies on. An unknown request ID creates a record; a match is
+// resolved by the strategy: server_wins keeps the server row, client_wins
+// overwrites it, newest_wins keeps whichever side was updated later. Every
+// item must carry a request ID, and binary records are rejected like on the
+// unary create path. Each record resolves in its own transaction, so a
+// failure mid-batch leaves the earlier outcomes committed and reports the
+// position that failed.
+func (s *Record) ImportRecords(ctx context.Context, userID uuid.UUID, items []ImportRecordItem, strategy ImportStrategy) ([]ImportResult, error) {
+	switch strategy {
+	case ImportServerWins, ImportClientWins, ImportNewestWins:
+	default:
+		return nil, apiErrors.NewErrInvalidMetadata(fmt.Sprintf("unknown import strategy %q", strategy))
+	}
+	if len(items) > maxImportRecords {
+		return nil, apiErrors.NewErrBatchTooLarge(maxImportRecords)
+	}
+	for i := range items {
+		if items[i].RequestID == nil {
+			return nil, apiErrors.NewErrInvalidMetadata(fmt.Sprintf("import record %d has no request id", i))
+		}
+	}
+
+	results := make([]ImportResult, 0, len(items))
+	for i := range items {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		record, wasDuplicate, err := s.CreateRecord(ctx, userID, items[i].CreateRecordParams)
+		if err != nil {
+			return results, fmt.Errorf("import record %d: %w", i, err)
+		}
+		if !wasDuplicate {
+			results = append(results, ImportResult{RecordID: record.ID, Status: ImportStatusCreated})
+			continue
+		}
+		if !importShouldOverwrite(strategy, record, items[i].UpdatedAt) {
+			results = append(results, ImportResult{RecordID: record.ID, Status: ImportStatusSkipped})
+			continue
+		}
+		updated := &model.Record{
+			ID:            record.ID,
+			Type:          items[i].Type,
+			Name:          items[i].Name,
+			Description:   s.sanitizeDescription(items[i].Description),
+			EncryptedData: items[i].EncryptedData,
+			EncryptedKey:  items[i].EncryptedKey,
+			Alg:           items[i].Alg,
+			Compression:   items[i].Compression,
+			Tags:          items[i].Tags,
+			Pinned:        items[i].Pinned,
+		}
+		if err := s.recordStore.ReplaceContent(ctx, updated, time.Now()); err != nil {
+			return results, fmt.Errorf("import record %d: %w", i, err)
+		}
+		// The overwrite made the row inline-only; an object backing a
+		// previously promoted payload is no longer referenced. A failed
+		// deletion is logged and left to operators, like during the purge.
+		if record.S3Key != "" {
+			if err := s.storage.Delete(ctx, record.S3Key); err != nil {
+				s.logger.Error("failed to delete replaced object", "key", record.S3Key, "error", err)
+			}
+		}
+		results = append(results, ImportResult{RecordID: record.ID, Status: ImportStatusUpdated})
+	}
+	return results, nil
+}
+
+// importShouldOverwrite resolves an import conflict against the server row.
+func importShouldOverwrite(strategy ImportStrategy, server *model.Record, clientUpdatedAt time.Time) bool {
+	switch strategy {
+	case ImportClientWins:
+		return true
+	case ImportNewestWins:
+		return clientUpdatedAt.After(server.UpdatedAt)
+	default:
+		return false
+	}
+}
+
+// RekeyRecord replaces a record's encrypted data key and algorithm after a
+// client-side master-key rotation. The payload and any stored object stay
+// untouched: only the key envelope changes, so binaries need no re-upload.
+func (s *Record) RekeyRecord(ctx context.Context, userID, recordID uuid.UUID, newEncryptedKey []byte, newAlg string) error {
+	if err := s.validateKeyEnvelope(newEncryptedKey, newAlg); err != nil {
+		return err
+	}
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return err
+	}
+	if err := s.recordStore.Rekey(ctx, record.ID, newEncryptedKey, newAlg, time.Now()); err != nil {
+		return fmt.Errorf("rekey record: %w", err)
+	}
+	return nil
+}
+
+// StorageUsageReport is what GetStorageUsage returns: the stored-byte and
+// per-type record aggregates plus the configured record quota, so clients can
+// render usage against the limit. MaxRecords zero means unlimited.
+type StorageUsageReport struct {
+	TotalBytes   int64
+	TotalRecords int
+	CountByType  map[model.RecordType]int
+	MaxRecords   int
+}
+
+// GetStorageUsage aggregates the user's live records on demand. Soft-deleted
+// records are excluded, matching what the record quota counts.
+func (s *Record) GetStorageUsage(ctx context.Context, userID uuid.UUID) (*StorageUsageReport, error) {
+	usage, err := s.recordStore.UsageByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate usage: %w", err)
+	}
+	report := &StorageUsageReport{
+		TotalBytes:  usage.TotalBytes,
+		CountByType: usage.CountByType,
+		MaxRecords:  s.limits.MaxRecordsPerUser,
+	}
+	for _, count := range usage.CountByType {
+		report.TotalRecords += count
+	}
+	return report, nil
+}
+
+// defaultPresignedPutExpiry applies when PresignedPutExpiry is unset.
+const defaultPresignedPutExpiry = 15 * time.Minute
+
+// GetPresignedPutURL returns a URL the owner can PUT a binary record's new
+// encrypted contents to directly, bypassing the server's streaming path. The
+// record's metadata goes stale the moment the upload lands and stays stale
+// until the client calls FinalizeBinaryUpdate, which reconciles size and ETag
+// from a stat; a client that never finalizes leaves the old values in place
+// until the next finalize or replacement upload reconciles them.
+func (s *Record) GetPresignedPutURL(ctx context.Context, userID, recordID uuid.UUID) (string, time.Time, error) {
+	if !s.limits.PresignedUploads {
+		return "", time.Time{}, apiErrors.NewErrPresignedDisabled()
+	}
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if record.S3Key == "" {
+		return "", time.Time{}, apiErrors.NewErrNotObjectBacked()
+	}
+	expiry := s.limits.PresignedPutExpiry
+	if expiry <= 0 {
+		expiry = defaultPresignedPutExpiry
+	}
+	url, err := s.storage.PresignedPut(ctx, record.S3Key, expiry)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("presign put: %w", err)
+	}
+	return url, time.Now().Add(expiry), nil
+}
+
+// FinalizeBinaryUpdate reconciles a record's metadata with its stored object
+// after a presigned upload: size and ETag come from a stat of the object, and
+// updated_at and version advance so other devices pick the change up on their
+// next delta. Idempotent, so finalizing twice — or calling it to reconcile a
+// row suspected of being stale — is harmless.
+func (s *Record) FinalizeBinaryUpdate(ctx context.Context, userID, recordID uuid.UUID) (*model.Record, error) {
+	if !s.limits.PresignedUploads {
+		return nil, apiErrors.NewErrPresignedDisabled()
+	}
+	record, err := s.getOwnedRecord(ctx, userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record.S3Key == "" {
+		return nil, apiErrors.NewErrNotObjectBacked()
+	}
+	size, etag, err := s.storage.StatETag(ctx, record.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("stat uploaded object: %w", err)
+	}
+	now := time.Now()
+	if err := s.recordStore.UpdateObjectMeta(ctx, record.ID, size, etag, now); err != nil {
+		return nil, fmt.Errorf("update object meta: %w", err)
+	}
+	record.FileSize = size
+	record.ETag = etag
+	record.UpdatedAt = now
+	record.Version++
+	return record, nil
+}
+
+// purgeBatchSize caps how many expired tombstones one purge pass processes
+// per query.
+const purgeBatchSize = 100
+
+// PurgeDeleted hard-deletes records whose soft-delete is older than the
+// cutoff, removing the stored object of object-backed records first. An
+// object whose removal fails is skipped and retried on the next run. Returns
+// how many rows were purged.
+func (s *Record) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return s.purgeBatches(ctx, func(ctx context.Context, limit int) ([]*model.Record, error) {
+		return s.recordStore.ListDeletedBefore(ctx, before, limit)
+	})
+}
+
+// PruneEvents deletes outbox events that occurred before the cutoff, keeping
+// the record_events table bounded. A cursor reaching past the sync lookback
+// window is already told to run a full resync, so events older than the
+// window can never be served again; retaining them only grows the table.
+// Returns how many rows were deleted.
+func (s *Record) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := s.eventStore.DeleteOlderThan(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete record events: %w", err)
+	}
+	return deleted, nil
+}
+
+// purgeBatches repeatedly lists soft-deleted records via list and hard-deletes
+// them, removing the stored object of object-backed records first.
+func (s *Record) purgeBatches(ctx context.Context, list func(ctx context.Context, limit int) ([]*model.Record, error)) (int, error) {
+	purged := 0
+	for {
+		rows, err := list(ctx, purgeBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("list deleted records: %w", err)
+		}
+		// Object deletions run concurrently up to the configured limit; a
+		// failed deletion is logged and keeps its row for the next cycle
+		// instead of aborting the run.
+		deleted := make([]bool, len(rows))
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(s.jobConcurrency())
+		for i, record := range rows {
+			if record.S3Key == "" {
+				deleted[i] = true
+				continue
+			}
+			g.Go(func() error {
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+				if err := s.storage.Delete(gctx, record.S3Key); err != nil {
+					s.logger.Error("failed to delete object during purge", "key", record.S3Key, "error", err)
+					return nil
+				}
+				deleted[i] = true
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return purged, err
+		}
+		progressed := 0
+		for i, record := range rows {
+			if !deleted[i] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return purged, err
+			}
+			if err := s.recordStore.HardDelete(ctx, record.ID); err != nil {
+				return purged, fmt.Errorf("hard delete record: %w", err)
+			}
+			purged++
+			progressed++
+		}
+		if len(rows) < purgeBatchSize || progressed == 0 {
+			return purged, nil
+		}
+	}
+}
+
+// jobConcurrency is the bounded parallelism for background object-store
+// work, never below one.
+func (s *Record) jobConcurrency() int {
+	if s.limits.JobConcurrency > 1 {
+		return s.limits.JobConcurrency
+	}
+	return 1
+}
+
+// saveRecord uploads the payload to the object storage and then persists the
+// record row, removing the object again if the row cannot be created or if an
+// idempotent replay made the upload redundant.
+func (s *Record) saveRecord(ctx context.Context, record *model.Record, data io.Reader, size int64) (*model.Record, bool, error) {
+	record.S3Key = generateS3Key(s.keyPrefix, record.OwnerID, record.ID)
+
+	counted := &countingReader{r: data}
+	etag, err := s.storage.Upload(ctx, record.S3Key, counted, size)
+	if err != nil {
+		// Best-effort removal in case the backend kept a partial object.
+		if delErr := s.storage.Delete(ctx, record.S3Key); delErr != nil {
+			s.logger.Error("failed to clean up object after aborted upload", "key", record.S3Key, "error", delErr)
+		}
+		// Client-attributable aborts (idle timeout, cancellation) keep their
+		// own code instead of being reported as a storage failure.
+		var apiErr *apiErrors.APIError
+		if errors.As(err, &apiErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, false, fmt.Errorf("upload object: %w", err)
+		}
+		s.logger.Error("upload failed", "key", record.S3Key, "error", err)
+		return nil, false, apiErrors.NewErrUploadFailed()
+	}
+	// The streamed payload size is only known once the upload drained the
+	// pipe; store it so downloads can detect truncated objects. The ETag comes
+	// back with the upload, saving a stat call.
+	record.FileSize = counted.n
+	record.ETag = etag
+	if s.limits.VerifyUploads {
+		// The backend acked the put, but the row is only committed once the
+		// object is confirmed retrievable at the size that went in.
+		stored, statErr := s.storage.Stat(ctx, record.S3Key)
+		if statErr == nil && stored != counted.n {
+			statErr = fmt.Errorf("stored size %d does not match %d bytes written", stored, counted.n)
+		}
+		if statErr != nil {
+			if delErr := s.storage.Delete(ctx, record.S3Key); delErr != nil {
+				s.logger.Error("failed to clean up object after failed verification", "key", record.S3Key, "error", delErr)
+			}
+			s.logger.Error("post-upload verification failed", "key", record.S3Key, "error", statErr)
+			return nil, false, apiErrors.NewErrUploadFailed()
+		}
+	}
+	created, inserted, err := s.recordStore.Create(ctx, record)
+	if err != nil {
+		if delErr := s.storage.Delete(ctx, record.S3Key); delErr != nil {
+			// The object could not be removed either: tell the client an
+			// orphan may remain instead of burying that in the log.
+			s.logger.Error("failed to clean up object after create failure", "key", record.S3Key, "create_error", err, "error", delErr)
+			return nil, false, apiErrors.NewErrUploadOrphaned()
+		}
+		var apiErr *apiErrors.APIError
+		if errors.As(err, &apiErr) {
+			// E.g. the owning account vanished; the object is cleaned up and
+			// the specific code tells the client not to retry blindly.
+			return nil, false, fmt.Errorf("create record: %w", err)
+		}
+		s.logger.Error("create failed after upload, object removed", "key", record.S3Key, "error", err)
+		return nil, false, apiErrors.NewErrUploadNotRecorded()
+	}
+	if !inserted {
+		// The replayed row keeps its original object; the one uploaded for
+		// this call would be orphaned under the abandoned record ID.
+		s.metrics.IncIdempotentCreate()
+		s.logger.Info("idempotent create replayed", "record_id", created.ID, "request_id", record.RequestID)
+		if delErr := s.storage.Delete(ctx, record.S3Key); delErr != nil {
+			s.logger.Error("failed to clean up object after replayed create", "key", record.S3Key, "error", delErr)
+		}
+	}
+	return created, !inserted, nil
+}
+
+// sanitizeDescription neutralizes a description for deployments that opted in:
+// control characters other than newline and tab are dropped and the rest is
+// HTML-escaped, so an unescaping web frontend cannot be scripted through
+// stored metadata. With the option off the description passes through
+// verbatim. Runs after validation, so the UTF-8 check sees the client's
+// original bytes.
+func (s *Record) sanitizeDescription(description string) string {
+	if !s.limits.SanitizeDescriptions {
+		return description
+	}
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, description)
+	return html.EscapeString(cleaned)
+}
+
+// validateMetadata checks the client-supplied metadata shared by the unary and
+// streaming create paths. Any future update path must go through the same
+// checks so limits stay consistent.
+func (s *Record) validateMetadata(params CreateRecordParams) error {
+	if params.Name == "" {
+		return apiErrors.NewErrInvalidMetadata("name is required")
+	}
+	if len(params.Name) > s.limits.MaxNameLength {
+		return apiErrors.NewErrInvalidMetadata(fmt.Sprintf("name exceeds %d characters", s.limits.MaxNameLength))
+	}
+	if len(params.Description) > s.limits.MaxDescriptionLength {
+		return apiErrors.NewErrInvalidMetadata(fmt.Sprintf("description exceeds %d characters", s.limits.MaxDescriptionLength))
+	}
+	if !utf8.ValidString(params.Description) {
+		return apiErrors.NewErrInvalidMetadata("description is not valid utf-8")
+	}
+	if !params.Type.Valid() {
+		return apiErrors.NewErrInvalidRecordType(string(params.Type))
+	}
+	if err := s.validateTags(params.Tags); err != nil {
+		return err
+	}
+	return s.validateKeyEnvelope(params.EncryptedKey, params.Alg)
+}
+
+// checkRecordQuota rejects a create once the user owns the configured maximum
+// of live records. Soft-deleted records do not count toward the limit.
+func (s *Record) checkRecordQuota(ctx context.Context, userID uuid.UUID) error {
+	max := s.limits.MaxRecordsPerUser
+	if max <= 0 {
+		return nil
+	}
+	count, err := s.recordStore.CountByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("count records: %w", err)
+	}
+	if count >= max {
+		return apiErrors.NewErrQuotaExceeded(max)
+	}
+	return nil
+}
+
+// effectiveChunkSize resolves the chunk size persisted with a streamed
+// record: an omitted value takes the configured default, values above the
+// configured maximum are clamped, and values below the configured minimum are
+// rejected. The resolved value is persisted on the record so downloads later
+// allocate against the same boundaries the payload was chunked with. Binary
+// uploads must end up with a positive size; non-binary streamed uploads may
+// omit it entirely, which CreateRecordStream handles before erroring.
+func (s *Record) effectiveChunkSize(requested int64) (int64, error) {
+	size := requested
+	if size <= 0 {
+		size = s.limits.DefaultChunkSize
+	}
+	if size <= 0 {
+		return 0, apiErrors.NewErrInvalidMetadata("chunk size must be positive for binary records")
+	}
+	if min := s.limits.MinChunkSize; min > 0 && size < min {
+		return 0, apiErrors.NewErrInvalidMetadata(fmt.Sprintf("chunk size below minimum of %d bytes", min))
+	}
+	if max := s.limits.MaxUploadChunkSize; max > 0 && size > max {
+		size = max
+	}
+	if size > maxStoredChunkSize {
+		return 0, apiErrors.NewErrInvalidMetadata(fmt.Sprintf("chunk size exceeds %d bytes", maxStoredChunkSize))
+	}
+	return size, nil
+}
+
+// validateTags checks the tag count and individual tag lengths.
+func (s *Record) validateTags(tags []string) error {
+	if max := s.limits.MaxTagsPerRecord; max > 0 && len(tags) > max {
+		return apiErrors.NewErrInvalidMetadata(fmt.Sprintf("more than %d tags", max))
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			return apiErrors.NewErrInvalidMetadata("empty tag")
+		}
+		if max := s.limits.MaxTagLength; max > 0 && len(tag) > max {
+			return apiErrors.NewErrInvalidMetadata(fmt.Sprintf("tag exceeds %d characters", max))
+		}
+	}
+	return nil
+}
+
+// validateKeyEnvelope checks the encrypted data key and algorithm against the
+// configured limits. It is shared by create and rekey so both paths enforce
+// the same allowlist.
+func (s *Record) validateKeyEnvelope(encryptedKey []byte, alg string) error {
+	if len(encryptedKey) == 0 {
+		return apiErrors.NewErrInvalidMetadata("encrypted key is required")
+	}
+	if max := s.limits.MaxEncryptedKeySize; max > 0 && len(encryptedKey) > max {
+		return apiErrors.NewErrInvalidMetadata(fmt.Sprintf("encrypted key exceeds %d bytes", max))
+	}
+	if alg == "" {
+		return apiErrors.NewErrInvalidMetadata("alg is required")
+	}
+	if !s.algAllowed(alg) {
+		return apiErrors.NewErrInvalidMetadata(fmt.Sprintf("unsupported alg %q, supported: %s", alg, strings.Join(s.limits.AllowedAlgs, ", ")))
+	}
+	return nil
+}
+
+// algAllowed reports whether the algorithm identifier is on the configured
+// allowlist. An empty allowlist accepts everything.
+func (s *Record) algAllowed(alg string) bool {
+	if len(s.limits.AllowedAlgs) == 0 {
+		return true
+	}
+	for _, a := range s.limits.AllowedAlgs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// restoredCreatedAt returns the client-supplied original creation time for a
+// backup restore, clamped so it can never lie in the future. The zero time
+// leaves the server-side default in place.
+func restoredCreatedAt(params CreateRecordParams) time.Time {
+	if params.OriginalCreatedAt == nil {
+		return time.Time{}
+	}
+	if now := time.Now(); params.OriginalCreatedAt.After(now) {
+		return now
+	}
+	return *params.OriginalCreatedAt
+}
+
+// generateS3Key builds the object key for a record payload, under the
+// environment prefix when one is configured. The prefix only applies to new
+// objects: existing rows keep the stored key they were uploaded under, so
+// downloads and purges of old records are unaffected by a prefix change.
+func generateS3Key(prefix string, userID, recordID uuid.UUID) string {
+	return fmt.Sprintf("%s%s/%s", prefix, userID, recordID)
+}
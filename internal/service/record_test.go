@@ -0,0 +1,2809 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+type mockRecordStore struct {
+	records map[uuid.UUID]*model.Record
+	created []*model.Record
+}
+
+func newMockRecordStore() *mockRecordStore {
+	return &mockRecordStore{records: map[uuid.UUID]*model.Record{}}
+}
+
+func (m *mockRecordStore) Create(_ context.Context, record *model.Record) (*model.Record, bool, error) {
+	if record.RequestID != nil {
+		// Mirrors the partial unique index: soft-deleted rows do not conflict.
+		for _, r := range m.records {
+			if r.OwnerID == record.OwnerID && r.DeletedAt == nil && r.RequestID != nil && *r.RequestID == *record.RequestID {
+				return r, false, nil
+			}
+		}
+	}
+	now := time.Now()
+	stored := *record
+	stored.Version = 1
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now
+	}
+	stored.UpdatedAt = now
+	m.records[stored.ID] = &stored
+	m.created = append(m.created, &stored)
+	return &stored, true, nil
+}
+
+func (m *mockRecordStore) GetByID(_ context.Context, id uuid.UUID) (*model.Record, error) {
+	record, ok := m.records[id]
+	if !ok || record.DeletedAt != nil {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (m *mockRecordStore) GetByIDIncludingDeleted(_ context.Context, id uuid.UUID) (*model.Record, error) {
+	record, ok := m.records[id]
+	if !ok {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func (m *mockRecordStore) GetByIDs(_ context.Context, ids []uuid.UUID) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, id := range ids {
+		if r, ok := m.records[id]; ok && r.DeletedAt == nil {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) GetByUserID(_ context.Context, userID uuid.UUID) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt == nil {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) GetIDsByUserID(_ context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt == nil {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockRecordStore) CountByUserID(_ context.Context, userID uuid.UUID) (int, error) {
+	count := 0
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockRecordStore) UsageByUserID(_ context.Context, userID uuid.UUID) (*model.StorageUsage, error) {
+	usage := &model.StorageUsage{CountByType: map[model.RecordType]int{}}
+	for _, r := range m.records {
+		if r.OwnerID != userID || r.DeletedAt != nil {
+			continue
+		}
+		usage.CountByType[r.Type]++
+		usage.TotalBytes += int64(len(r.EncryptedData)) + r.FileSize
+	}
+	return usage, nil
+}
+
+func (m *mockRecordStore) GetByUserIDAndTag(_ context.Context, userID uuid.UUID, tag string) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, r := range m.records {
+		if r.OwnerID != userID || r.DeletedAt != nil {
+			continue
+		}
+		for _, t := range r.Tags {
+			if t == tag {
+				records = append(records, r)
+				break
+			}
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) SetTags(_ context.Context, id uuid.UUID, tags []string, at time.Time) error {
+	record, ok := m.records[id]
+	if !ok || record.DeletedAt != nil {
+		return errors.New("set tags: not found")
+	}
+	record.Tags = tags
+	record.UpdatedAt = at
+	record.Version++
+	return nil
+}
+
+func (m *mockRecordStore) GetPinnedByUserID(_ context.Context, userID uuid.UUID) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt == nil && r.Pinned {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) SetPinned(_ context.Context, id uuid.UUID, pinned bool, at time.Time) error {
+	record, ok := m.records[id]
+	if !ok || record.DeletedAt != nil {
+		return errors.New("set pinned: not found")
+	}
+	record.Pinned = pinned
+	record.UpdatedAt = at
+	record.Version++
+	return nil
+}
+
+func (m *mockRecordStore) SetType(_ context.Context, id uuid.UUID, t model.RecordType, at time.Time) error {
+	record, ok := m.records[id]
+	if !ok || record.DeletedAt != nil {
+		return errors.New("set type: not found")
+	}
+	record.Type = t
+	record.UpdatedAt = at
+	record.Version++
+	return nil
+}
+
+func (m *mockRecordStore) GetUpdatedAfter(_ context.Context, userID uuid.UUID, after time.Time) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt == nil && r.UpdatedAt.After(after) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) GetDeletedAfter(_ context.Context, userID uuid.UUID, after time.Time) ([]model.Tombstone, error) {
+	var tombstones []model.Tombstone
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt != nil && r.DeletedAt.After(after) {
+			tombstones = append(tombstones, model.Tombstone{ID: r.ID, DeletedAt: *r.DeletedAt})
+		}
+	}
+	return tombstones, nil
+}
+
+func (m *mockRecordStore) Rekey(_ context.Context, id uuid.UUID, encryptedKey []byte, alg string, at time.Time) error {
+	record, ok := m.records[id]
+	if !ok || record.DeletedAt != nil {
+		return errors.New("rekey record: not found")
+	}
+	record.EncryptedKey = encryptedKey
+	record.Alg = alg
+	record.UpdatedAt = at
+	record.Version++
+	return nil
+}
+
+func (m *mockRecordStore) UpdateObjectMeta(_ context.Context, id uuid.UUID, fileSize int64, etag string, at time.Time) error {
+	record, ok := m.records[id]
+	if !ok || record.DeletedAt != nil {
+		return errors.New("update object meta: not found")
+	}
+	record.FileSize = fileSize
+	record.ETag = etag
+	record.UpdatedAt = at
+	record.Version++
+	return nil
+}
+
+func (m *mockRecordStore) ReplaceContent(_ context.Context, record *model.Record, at time.Time) error {
+	existing, ok := m.records[record.ID]
+	if !ok || existing.DeletedAt != nil {
+		return errors.New("replace record content: not found")
+	}
+	existing.Type = record.Type
+	existing.Name = record.Name
+	existing.Description = record.Description
+	existing.EncryptedData = record.EncryptedData
+	existing.EncryptedKey = record.EncryptedKey
+	existing.Alg = record.Alg
+	existing.Compression = record.Compression
+	existing.Tags = record.Tags
+	existing.Pinned = record.Pinned
+	existing.S3Key = ""
+	existing.EncryptedChunkSize = 0
+	existing.FileSize = 0
+	existing.ETag = ""
+	existing.UpdatedAt = at
+	existing.Version++
+	return nil
+}
+
+func (m *mockRecordStore) Delete(_ context.Context, id uuid.UUID, deletedAt time.Time) error {
+	record, ok := m.records[id]
+	if !ok {
+		return nil
+	}
+	record.DeletedAt = &deletedAt
+	record.UpdatedAt = deletedAt
+	record.Version++
+	return nil
+}
+
+func (m *mockRecordStore) DeleteMany(_ context.Context, ids []uuid.UUID, deletedAt time.Time) error {
+	for _, id := range ids {
+		if record, ok := m.records[id]; ok && record.DeletedAt == nil {
+			record.DeletedAt = &deletedAt
+			record.UpdatedAt = deletedAt
+			record.Version++
+		}
+	}
+	return nil
+}
+
+func (m *mockRecordStore) ListDeletedBefore(_ context.Context, before time.Time, limit int) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, r := range m.records {
+		if r.DeletedAt != nil && r.DeletedAt.Before(before) {
+			records = append(records, r)
+		}
+		if len(records) == limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) ListDeletedBeforeForUser(_ context.Context, userID uuid.UUID, before time.Time, limit int) ([]*model.Record, error) {
+	var records []*model.Record
+	for _, r := range m.records {
+		if r.OwnerID == userID && r.DeletedAt != nil && r.DeletedAt.Before(before) {
+			records = append(records, r)
+		}
+		if len(records) == limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (m *mockRecordStore) HardDelete(_ context.Context, id uuid.UUID) error {
+	if r, ok := m.records[id]; ok && r.DeletedAt != nil {
+		delete(m.records, id)
+	}
+	return nil
+}
+
+func (m *mockRecordStore) TransferOwner(_ context.Context, id uuid.UUID, newOwnerID uuid.UUID, at time.Time) error {
+	record, ok := m.records[id]
+	if !ok {
+		return errors.New("transfer record: not found")
+	}
+	record.OwnerID = newOwnerID
+	record.RequestID = nil
+	record.UpdatedAt = at
+	record.Version++
+	return nil
+}
+
+// mockAckStore keeps per-device tombstone acknowledgments in memory.
+type mockAckStore struct {
+	acks map[uuid.UUID]map[string]time.Time
+}
+
+func newMockAckStore() *mockAckStore {
+	return &mockAckStore{acks: map[uuid.UUID]map[string]time.Time{}}
+}
+
+func (m *mockAckStore) Upsert(_ context.Context, userID uuid.UUID, deviceID string, ackedBefore, _ time.Time) error {
+	devices, ok := m.acks[userID]
+	if !ok {
+		devices = map[string]time.Time{}
+		m.acks[userID] = devices
+	}
+	if ackedBefore.After(devices[deviceID]) {
+		devices[deviceID] = ackedBefore
+	}
+	return nil
+}
+
+func (m *mockAckStore) HighWaterMark(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	var mark time.Time
+	for _, acked := range m.acks[userID] {
+		if mark.IsZero() || acked.Before(mark) {
+			mark = acked
+		}
+	}
+	return mark, nil
+}
+
+type mockUserStore struct {
+	users map[uuid.UUID]*model.User
+}
+
+func newMockUserStore(ids ...uuid.UUID) *mockUserStore {
+	users := map[uuid.UUID]*model.User{}
+	for _, id := range ids {
+		users[id] = &model.User{ID: id}
+	}
+	return &mockUserStore{users: users}
+}
+
+func (m *mockUserStore) Create(_ context.Context, user *model.User) (*model.User, error) {
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *mockUserStore) GetByID(_ context.Context, id uuid.UUID) (*model.User, error) {
+	return m.users[id], nil
+}
+
+func (m *mockUserStore) GetByEmail(_ context.Context, email string) (*model.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockUserStore) List(_ context.Context, afterID uuid.UUID, limit int) ([]*model.User, error) {
+	var users []*model.User
+	for _, u := range m.users {
+		if u.ID.String() > afterID.String() {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.String() < users[j].ID.String() })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (m *mockUserStore) UpdateKDF(_ context.Context, id uuid.UUID, kdf []byte) error {
+	if u, ok := m.users[id]; ok {
+		u.KDF = kdf
+	}
+	return nil
+}
+
+type mockStorage struct {
+	objects map[string][]byte
+}
+
+func newMockStorage() *mockStorage {
+	return &mockStorage{objects: map[string][]byte{}}
+}
+
+func (m *mockStorage) Upload(_ context.Context, key string, data io.Reader, _ int64) (string, error) {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return "", err
+	}
+	m.objects[key] = content
+	return fmt.Sprintf("etag-%x", sha256.Sum256(content)), nil
+}
+
+func (m *mockStorage) Download(_ context.Context, key string) (io.ReadCloser, error) {
+	content, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m *mockStorage) Delete(_ context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *mockStorage) Exists(_ context.Context, key string) (bool, error) {
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+func (m *mockStorage) Stat(_ context.Context, key string) (int64, error) {
+	content, ok := m.objects[key]
+	if !ok {
+		return 0, errors.New("object not found")
+	}
+	return int64(len(content)), nil
+}
+
+func (m *mockStorage) StatETag(_ context.Context, key string) (int64, string, error) {
+	content, ok := m.objects[key]
+	if !ok {
+		return 0, "", errors.New("object not found")
+	}
+	return int64(len(content)), fmt.Sprintf("etag-%x", sha256.Sum256(content)), nil
+}
+
+func (m *mockStorage) PresignedPut(_ context.Context, key string, expiry time.Duration) (string, error) {
+	return fmt.Sprintf("https://minio.test/%s?expires=%d", key, int64(expiry.Seconds())), nil
+}
+
+func (m *mockStorage) List(_ context.Context, afterKey string, limit int) ([]string, error) {
+	var keys []string
+	for key := range m.objects {
+		if key > afterKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+func (m *mockStorage) Copy(_ context.Context, key string) error {
+	if _, ok := m.objects[key]; !ok {
+		return errors.New("object not found")
+	}
+	return nil
+}
+
+// sliceChunkReceiver feeds predefined chunks then io.EOF.
+type sliceChunkReceiver struct {
+	chunks [][]byte
+}
+
+func (r *sliceChunkReceiver) Recv() ([]byte, error) {
+	if len(r.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	return chunk, nil
+}
+
+type mockEventStore struct {
+	events []model.RecordEvent
+}
+
+func (m *mockEventStore) ListAfter(_ context.Context, userID uuid.UUID, afterSeq int64, limit int) ([]model.RecordEvent, error) {
+	var events []model.RecordEvent
+	for _, e := range m.events {
+		if e.UserID == userID && e.Seq > afterSeq {
+			events = append(events, e)
+		}
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (m *mockEventStore) DeleteOlderThan(_ context.Context, before time.Time) (int64, error) {
+	var kept []model.RecordEvent
+	for _, e := range m.events {
+		if !e.OccurredAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	deleted := int64(len(m.events) - len(kept))
+	m.events = kept
+	return deleted, nil
+}
+
+func newRecordService(store *mockRecordStore, users *mockUserStore, storage *mockStorage) *Record {
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxChunkSize: 1 << 20, MaxInlineBinarySize: 256 << 10}
+	return NewRecord(store, users, &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+}
+
+func TestCreateRecord_Success(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "my note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.OwnerID != userID {
+		t.Errorf("owner = %s, want %s", record.OwnerID, userID)
+	}
+	if len(store.created) != 1 {
+		t.Errorf("created %d records, want 1", len(store.created))
+	}
+}
+
+func TestCreateRecord_RejectsBinary(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	_, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+	})
+	if err == nil {
+		t.Fatal("expected error for binary record on unary path")
+	}
+}
+
+func TestCreateRecord_ValidatesName(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	_, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+	})
+	if err == nil {
+		t.Fatal("expected error for empty name")
+	}
+}
+
+func TestCreateRecordStream_SuccessBinary(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	chunks := &sliceChunkReceiver{chunks: [][]byte{[]byte("aaaa"), []byte("bbbb"), []byte("cc")}}
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, chunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored := storage.objects[record.S3Key]
+	if string(stored) != "aaaabbbbcc" {
+		t.Errorf("stored %q, want %q", stored, "aaaabbbbcc")
+	}
+}
+
+// blockingStorage blocks Upload until the request context is cancelled,
+// simulating a stalled object store.
+type blockingStorage struct {
+	*mockStorage
+	started chan struct{}
+}
+
+func (s *blockingStorage) Upload(ctx context.Context, _ string, _ io.Reader, _ int64) (string, error) {
+	close(s.started)
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+// blockingChunkReceiver delivers one chunk and then blocks until the context
+// is cancelled, like a gRPC Recv on a disconnected client.
+type blockingChunkReceiver struct {
+	ctx  context.Context
+	sent bool
+}
+
+func (r *blockingChunkReceiver) Recv() ([]byte, error) {
+	if !r.sent {
+		r.sent = true
+		return []byte("chunk"), nil
+	}
+	<-r.ctx.Done()
+	return nil, r.ctx.Err()
+}
+
+func TestCreateRecordStream_CancelledContextNoLeak(t *testing.T) {
+	userID := uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := &blockingStorage{mockStorage: newMockStorage(), started: make(chan struct{})}
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), &mockEventStore{}, nil, storage, RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}, "", false, nil, logger.NewNoop())
+
+	before := runtime.NumGoroutine()
+	go func() {
+		<-storage.started
+		cancel()
+	}()
+
+	_, _, err := svc.CreateRecordStream(ctx, userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, &blockingChunkReceiver{ctx: ctx})
+	if err == nil {
+		t.Fatal("expected error on cancelled context")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("goroutines = %d, want <= %d: reader goroutine leaked", got, before)
+	}
+}
+
+func TestGetRecord_NotOwned(t *testing.T) {
+	owner := uuid.New()
+	other := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(owner, other), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), owner, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "secret",
+		EncryptedData: []byte("x"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = svc.GetRecord(context.Background(), other, record.ID, false)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "record not found" {
+		t.Errorf("err = %v, want record not found", err)
+	}
+}
+
+func TestGetByIDs_DropsForeignRecords(t *testing.T) {
+	owner := uuid.New()
+	other := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(owner, other), newMockStorage())
+
+	mine, _, err := svc.CreateRecord(context.Background(), owner, CreateRecordParams{
+		Type: model.RecordTypeNote, Name: "mine",
+		EncryptedData: []byte("x"), EncryptedKey: []byte("key"), Alg: "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	theirs, _, err := svc.CreateRecord(context.Background(), other, CreateRecordParams{
+		Type: model.RecordTypeNote, Name: "theirs",
+		EncryptedData: []byte("x"), EncryptedKey: []byte("key"), Alg: "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := svc.GetByIDs(context.Background(), owner, []uuid.UUID{mine.ID, theirs.ID, uuid.New()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != mine.ID {
+		t.Errorf("records = %v, want only owned record", records)
+	}
+}
+
+func TestGetByIDs_CapsBatchSize(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	ids := make([]uuid.UUID, maxBatchGetIDs+1)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	if _, err := svc.GetByIDs(context.Background(), userID, ids); err == nil {
+		t.Error("expected error for oversized batch")
+	}
+}
+
+func TestDeleteRecord_SoftDeletes(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("x"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.DeleteRecord(context.Background(), userID, record.ID, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.records[record.ID].DeletedAt == nil {
+		t.Error("record not soft-deleted")
+	}
+}
+
+func TestCreateRecord_DryRunDoesNotPersist(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "draft",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-gcm",
+		DryRun:       true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record != nil {
+		t.Errorf("record = %+v, want nil for dry run", record)
+	}
+	if len(store.created) != 0 {
+		t.Errorf("records persisted during dry run: %d", len(store.created))
+	}
+	if len(storage.objects) != 0 {
+		t.Errorf("objects uploaded during dry run: %d", len(storage.objects))
+	}
+}
+
+func TestCreateRecord_DryRunStillValidates(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	_, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:   model.RecordTypeNote,
+		Name:   "",
+		DryRun: true,
+	})
+	if err == nil {
+		t.Error("expected validation error for empty name")
+	}
+}
+
+func TestCreateRecord_ValidatesDescriptionLimit(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	_, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "long description",
+		Description:  strings.Repeat("x", 4097),
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-gcm",
+	})
+	if err == nil {
+		t.Error("expected error for oversized description")
+	}
+}
+
+// collectingEventSender records sent events.
+type collectingEventSender struct {
+	events []model.RecordEvent
+}
+
+func (s *collectingEventSender) SendEvent(event model.RecordEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestWatchRecords_ReplaysAfterCursorAndStopsOnCancel(t *testing.T) {
+	userID := uuid.New()
+	events := &mockEventStore{events: []model.RecordEvent{
+		{Seq: 1, UserID: userID, RecordID: uuid.New(), EventType: model.RecordEventCreated},
+		{Seq: 2, UserID: userID, RecordID: uuid.New(), EventType: model.RecordEventDeleted},
+		{Seq: 3, UserID: uuid.New(), RecordID: uuid.New(), EventType: model.RecordEventCreated},
+	}}
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), events, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := &collectingEventSender{}
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.WatchRecords(ctx, userID, 1, sender)
+	}()
+
+	// Give the first poll time to deliver before cancelling.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("WatchRecords returned %v, want nil on cancellation", err)
+	}
+	if len(sender.events) != 1 || sender.events[0].Seq != 2 {
+		t.Errorf("events = %+v, want only seq 2 for this user after cursor 1", sender.events)
+	}
+}
+
+func TestPruneEvents_DeletesOnlyOlderThanCutoff(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	events := &mockEventStore{events: []model.RecordEvent{
+		{Seq: 1, UserID: userID, RecordID: uuid.New(), EventType: model.RecordEventCreated, OccurredAt: now.Add(-48 * time.Hour)},
+		{Seq: 2, UserID: userID, RecordID: uuid.New(), EventType: model.RecordEventUpdated, OccurredAt: now.Add(-time.Hour)},
+	}}
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), events, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	pruned, err := svc.PruneEvents(context.Background(), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned = %d, want 1", pruned)
+	}
+	if len(events.events) != 1 || events.events[0].Seq != 2 {
+		t.Errorf("remaining events = %+v, want only seq 2", events.events)
+	}
+}
+
+func TestDeleteRecord_VersionConflict(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("x"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = svc.DeleteRecord(context.Background(), userID, record.ID, record.Version+1, false)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.Aborted {
+		t.Errorf("err = %v, want Aborted version conflict", err)
+	}
+	if store.records[record.ID].DeletedAt != nil {
+		t.Error("record deleted despite version conflict")
+	}
+}
+
+func TestCreateRecord_PreservesOriginalCreatedAt(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	original := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:              model.RecordTypeNote,
+		Name:              "restored",
+		EncryptedData:     []byte("x"),
+		EncryptedKey:      []byte("key"),
+		Alg:               "aes-256-gcm",
+		OriginalCreatedAt: &original,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !record.CreatedAt.Equal(original) {
+		t.Errorf("created_at = %v, want %v", record.CreatedAt, original)
+	}
+	if record.UpdatedAt.Equal(original) {
+		t.Error("updated_at must stay server-side for delta sync")
+	}
+}
+
+func TestCreateRecord_ClampsFutureCreatedAt(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	future := time.Now().Add(time.Hour)
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:              model.RecordTypeNote,
+		Name:              "restored",
+		EncryptedData:     []byte("x"),
+		EncryptedKey:      []byte("key"),
+		Alg:               "aes-256-gcm",
+		OriginalCreatedAt: &future,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.CreatedAt.After(time.Now()) {
+		t.Errorf("created_at = %v lies in the future", record.CreatedAt)
+	}
+}
+
+func TestTransferRecord_ReassignsOwner(t *testing.T) {
+	fromID, toID := uuid.New(), uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(fromID, toID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), fromID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "shared note",
+		EncryptedData: []byte("x"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.TransferRecord(context.Background(), fromID, record.ID, toID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.records[record.ID].OwnerID != toID {
+		t.Errorf("owner = %s, want %s", store.records[record.ID].OwnerID, toID)
+	}
+	if store.records[record.ID].RequestID != nil {
+		t.Error("request_id not cleared on transfer")
+	}
+}
+
+func TestTransferRecord_RejectsNonOwner(t *testing.T) {
+	ownerID, otherID, toID := uuid.New(), uuid.New(), uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(ownerID, otherID, toID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), ownerID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("x"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.TransferRecord(context.Background(), otherID, record.ID, toID); err == nil {
+		t.Error("expected error for transfer by non-owner")
+	}
+	if store.records[record.ID].OwnerID != ownerID {
+		t.Error("ownership changed despite rejection")
+	}
+}
+
+// collectingExportSender records the sequence of export events.
+type collectingExportSender struct {
+	metadata []uuid.UUID
+	chunks   [][]byte
+	failed   []uuid.UUID
+}
+
+func (s *collectingExportSender) SendMetadata(record *model.Record) error {
+	s.metadata = append(s.metadata, record.ID)
+	return nil
+}
+
+func (s *collectingExportSender) SendChunk(data []byte, last bool) error {
+	s.chunks = append(s.chunks, data)
+	return nil
+}
+
+func (s *collectingExportSender) SendRecordError(recordID uuid.UUID, _ string) error {
+	s.failed = append(s.failed, recordID)
+	return nil
+}
+
+func TestExportRecords_ContinuesPastMissingObject(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	note, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A binary record whose object was never uploaded (or got lost).
+	broken := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		Type:               model.RecordTypeBinary,
+		Name:               "lost",
+		S3Key:              "missing/key",
+		EncryptedChunkSize: 4,
+	}
+	if _, _, err := store.Create(context.Background(), broken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sender := &collectingExportSender{}
+	if err := svc.ExportRecords(context.Background(), userID, sender); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(sender.metadata) != 1 || sender.metadata[0] != note.ID {
+		t.Errorf("metadata = %v, want only %s", sender.metadata, note.ID)
+	}
+	if len(sender.chunks) != 1 || !bytes.Equal(sender.chunks[0], []byte("ciphertext")) {
+		t.Errorf("chunks = %v, want inline note payload", sender.chunks)
+	}
+	if len(sender.failed) != 1 || sender.failed[0] != broken.ID {
+		t.Errorf("failed = %v, want %s", sender.failed, broken.ID)
+	}
+}
+
+// collectingChunkSender records the streamed metadata and chunks.
+type collectingChunkSender struct {
+	record *model.Record
+	chunks [][]byte
+}
+
+func (s *collectingChunkSender) SendMetadata(record *model.Record) error {
+	s.record = record
+	return nil
+}
+
+func (s *collectingChunkSender) SendChunk(data []byte, last bool) error {
+	s.chunks = append(s.chunks, append([]byte(nil), data...))
+	return nil
+}
+
+func TestStreamRecordToClient_ClampsOversizedChunkSize(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxChunkSize: 4}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	payload := []byte("0123456789")
+	record := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		Type:               model.RecordTypeBinary,
+		Name:               "big chunks",
+		Alg:                "aes-256-gcm",
+		S3Key:              "key",
+		EncryptedChunkSize: 1 << 20,
+	}
+	if _, _, err := store.Create(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	storage.objects["key"] = payload
+
+	sender := &collectingChunkSender{}
+	if err := svc.StreamRecordToClient(context.Background(), userID, record.ID, sender); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []byte
+	for _, chunk := range sender.chunks {
+		if int64(len(chunk)) > limits.MaxChunkSize {
+			t.Errorf("chunk of %d bytes exceeds clamp %d", len(chunk), limits.MaxChunkSize)
+		}
+		got = append(got, chunk...)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("reassembled payload = %q, want %q", got, payload)
+	}
+}
+
+func TestStreamRecordToClient_RejectsAbsurdStoredChunkSize(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	record := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		Type:               model.RecordTypeBinary,
+		Name:               "corrupt",
+		Alg:                "aes-256-gcm",
+		S3Key:              "key",
+		EncryptedChunkSize: maxStoredChunkSize + 1,
+	}
+	if _, _, err := store.Create(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	storage.objects["key"] = []byte("data")
+
+	if err := svc.StreamRecordToClient(context.Background(), userID, record.ID, &collectingChunkSender{}); err == nil {
+		t.Fatal("expected error for chunk size above the hard ceiling")
+	}
+}
+
+func TestStreamRecordToClient_RejectsBadStoredAlg(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, AllowedAlgs: []string{"aes-256-gcm"}}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	for _, alg := range []string{"", "rot13"} {
+		record := &model.Record{
+			ID:                 uuid.New(),
+			OwnerID:            userID,
+			Type:               model.RecordTypeBinary,
+			Name:               "legacy",
+			Alg:                alg,
+			S3Key:              "key",
+			EncryptedChunkSize: 8,
+		}
+		if _, _, err := store.Create(context.Background(), record); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		storage.objects["key"] = []byte("data")
+
+		sender := &collectingChunkSender{}
+		if err := svc.StreamRecordToClient(context.Background(), userID, record.ID, sender); err == nil {
+			t.Errorf("alg %q: expected error for record outside the allowlist", alg)
+		}
+		if sender.record != nil || len(sender.chunks) > 0 {
+			t.Errorf("alg %q: expected nothing streamed before the alg check failed", alg)
+		}
+	}
+}
+
+func TestGetRecord_InlinesSmallBinary(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	record := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		Type:               model.RecordTypeBinary,
+		Name:               "small file",
+		S3Key:              "key",
+		EncryptedChunkSize: 1024,
+	}
+	if _, _, err := store.Create(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	storage.objects["key"] = []byte("tiny payload")
+
+	got, err := svc.GetRecord(context.Background(), userID, record.ID, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got.EncryptedData, []byte("tiny payload")) {
+		t.Errorf("EncryptedData = %q, want inlined payload", got.EncryptedData)
+	}
+}
+
+func TestGetRecord_RejectsOversizedBinaryInline(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxChunkSize: 1 << 20, MaxInlineBinarySize: 4}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	record := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		Type:               model.RecordTypeBinary,
+		Name:               "big file",
+		S3Key:              "key",
+		EncryptedChunkSize: 1024,
+	}
+	if _, _, err := store.Create(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	storage.objects["key"] = []byte("more than four bytes")
+
+	_, err := svc.GetRecord(context.Background(), userID, record.ID, false)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.FailedPrecondition {
+		t.Fatalf("err = %v, want FailedPrecondition", err)
+	}
+}
+
+func TestCreateRecord_ReportsIdempotentReplay(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	requestID := uuid.New()
+	params := CreateRecordParams{
+		RequestID:     &requestID,
+		Type:          model.RecordTypeNote,
+		Name:          "my note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}
+
+	first, wasDuplicate, err := svc.CreateRecord(context.Background(), userID, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wasDuplicate {
+		t.Error("first create reported as duplicate")
+	}
+
+	replayed, wasDuplicate, err := svc.CreateRecord(context.Background(), userID, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !wasDuplicate {
+		t.Error("replayed create not reported as duplicate")
+	}
+	if replayed.ID != first.ID {
+		t.Errorf("replayed ID = %s, want %s", replayed.ID, first.ID)
+	}
+	if len(store.created) != 1 {
+		t.Errorf("created %d records, want 1", len(store.created))
+	}
+}
+
+func TestCreateRecord_EnforcesPerTypePayloadLimit(t *testing.T) {
+	userID := uuid.New()
+	limits := RecordLimits{
+		MaxNameLength:        255,
+		MaxDescriptionLength: 4096,
+		MaxPayloadSize:       map[model.RecordType]int{model.RecordTypeNote: 8},
+	}
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	_, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "long note",
+		EncryptedData: []byte("more than eight bytes"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+
+	// A login record has no cap configured here and must pass.
+	if _, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeLogin,
+		Name:          "login",
+		EncryptedData: []byte("more than eight bytes"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDeleteRecord_RetainsObjectUntilPurge(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("data")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.DeleteRecord(context.Background(), userID, record.ID, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := storage.objects[record.S3Key]; !ok {
+		t.Error("object removed on soft-delete, want retained for purge")
+	}
+
+	purged, err := svc.PurgeDeleted(context.Background(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged = %d, want 1", purged)
+	}
+	if _, ok := storage.objects[record.S3Key]; ok {
+		t.Error("object still present after purge")
+	}
+	if _, ok := store.records[record.ID]; ok {
+		t.Error("row still present after purge")
+	}
+}
+
+func TestDeleteRecord_PurgeFlagRemovesObjectImmediately(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("data")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.DeleteRecord(context.Background(), userID, record.ID, 0, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := storage.objects[record.S3Key]; ok {
+		t.Error("object still present after compliance delete")
+	}
+}
+
+func TestRekeyRecord_UpdatesKeyOnly(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("old key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.RekeyRecord(context.Background(), userID, record.ID, []byte("new key"), "xchacha20-poly1305"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored := store.records[record.ID]
+	if !bytes.Equal(stored.EncryptedKey, []byte("new key")) || stored.Alg != "xchacha20-poly1305" {
+		t.Errorf("key/alg = %q/%q, want rekeyed values", stored.EncryptedKey, stored.Alg)
+	}
+	if !bytes.Equal(stored.EncryptedData, []byte("ciphertext")) {
+		t.Error("payload changed during rekey")
+	}
+}
+
+func TestRekeyRecord_RejectsNonOwner(t *testing.T) {
+	owner := uuid.New()
+	other := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(owner, other), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), owner, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = svc.RekeyRecord(context.Background(), other, record.ID, []byte("new key"), "aes-256-gcm")
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound for foreign record", err)
+	}
+}
+
+func TestGetRecord_ExposeForbiddenReportsPermissionDenied(t *testing.T) {
+	owner := uuid.New()
+	other := uuid.New()
+	store := newMockRecordStore()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}
+	svc := NewRecord(store, newMockUserStore(owner, other), &mockEventStore{}, nil, newMockStorage(), limits, "", true, nil, logger.NewNoop())
+
+	record, _, err := svc.CreateRecord(context.Background(), owner, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = svc.GetRecord(context.Background(), other, record.ID, false)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied with exposeForbidden", err)
+	}
+
+	// A genuinely missing record stays NotFound regardless of the flag.
+	_, err = svc.GetRecord(context.Background(), other, uuid.New(), false)
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound for missing record", err)
+	}
+}
+
+func TestBatchDeleteRecords_ReportsPerIDOutcome(t *testing.T) {
+	owner := uuid.New()
+	other := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(owner, other), newMockStorage())
+
+	mine, _, err := svc.CreateRecord(context.Background(), owner, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "mine",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	foreign, _, err := svc.CreateRecord(context.Background(), other, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "foreign",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	missing := uuid.New()
+
+	results, err := svc.BatchDeleteRecords(context.Background(), owner, []uuid.UUID{mine.ID, foreign.ID, missing})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []BatchDeleteStatus{BatchDeleteStatusDeleted, BatchDeleteStatusNotFound, BatchDeleteStatusNotFound}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("result[%d] = %s, want %s", i, result.Status, want[i])
+		}
+	}
+	if store.records[mine.ID].DeletedAt == nil {
+		t.Error("owned record was not soft-deleted")
+	}
+	if store.records[foreign.ID].DeletedAt != nil {
+		t.Error("foreign record was deleted")
+	}
+}
+
+func TestBatchDeleteRecords_RejectsOversizedBatch(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	ids := make([]uuid.UUID, maxBatchDeleteIDs+1)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	_, err := svc.BatchDeleteRecords(context.Background(), userID, ids)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestCreateRecord_EnforcesAlgAllowlistAndKeySize(t *testing.T) {
+	userID := uuid.New()
+	limits := RecordLimits{
+		MaxNameLength:        255,
+		MaxDescriptionLength: 4096,
+		AllowedAlgs:          []string{"aes-256-gcm"},
+		MaxEncryptedKeySize:  16,
+	}
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	params := CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "rot13",
+	}
+	_, _, err := svc.CreateRecord(context.Background(), userID, params)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument for unknown alg", err)
+	}
+	if !strings.Contains(apiErr.Message, "rot13") {
+		t.Errorf("message = %q, want it to name the rejected alg", apiErr.Message)
+	}
+
+	params.Alg = "aes-256-gcm"
+	params.EncryptedKey = bytes.Repeat([]byte("k"), 17)
+	_, _, err = svc.CreateRecord(context.Background(), userID, params)
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument for oversized key", err)
+	}
+
+	params.EncryptedKey = []byte("key")
+	if _, _, err := svc.CreateRecord(context.Background(), userID, params); err != nil {
+		t.Fatalf("unexpected error for allowed alg: %v", err)
+	}
+}
+
+// eofBundledChunkReceiver returns the last chunk together with io.EOF, which
+// gRPC permits on a final Recv.
+type eofBundledChunkReceiver struct {
+	chunks [][]byte
+}
+
+func (r *eofBundledChunkReceiver) Recv() ([]byte, error) {
+	if len(r.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	if len(r.chunks) == 0 {
+		return chunk, io.EOF
+	}
+	return chunk, nil
+}
+
+func TestCreateRecordStream_FinalChunkBundledWithEOF(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	chunks := &eofBundledChunkReceiver{chunks: [][]byte{[]byte("aaaa"), []byte("bbbb"), []byte("cc")}}
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, chunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored := storage.objects[record.S3Key]
+	if string(stored) != "aaaabbbbcc" {
+		t.Errorf("stored %q, want %q", stored, "aaaabbbbcc")
+	}
+}
+
+// stallingChunkReceiver delivers its chunks then blocks until released.
+type stallingChunkReceiver struct {
+	chunks  [][]byte
+	release chan struct{}
+}
+
+func (r *stallingChunkReceiver) Recv() ([]byte, error) {
+	if len(r.chunks) > 0 {
+		chunk := r.chunks[0]
+		r.chunks = r.chunks[1:]
+		return chunk, nil
+	}
+	<-r.release
+	return nil, io.EOF
+}
+
+func TestCreateRecordStream_IdleTimeoutAbortsUpload(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{
+		MaxNameLength:        255,
+		MaxDescriptionLength: 4096,
+		UploadIdleTimeout:    20 * time.Millisecond,
+	}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	chunks := &stallingChunkReceiver{chunks: [][]byte{[]byte("aaaa")}, release: make(chan struct{})}
+	defer close(chunks.release)
+
+	_, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, chunks)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded after idle timeout", err)
+	}
+	if len(storage.objects) != 0 {
+		t.Errorf("storage holds %d objects, want the partial upload cleaned up", len(storage.objects))
+	}
+	if len(store.created) != 0 {
+		t.Error("record row was created despite aborted upload")
+	}
+}
+
+func TestRecordTags_SetAndFilter(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxTagsPerRecord: 4, MaxTagLength: 16}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+		Tags:          []string{"work"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "other",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := svc.GetByTag(context.Background(), userID, "work")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != record.ID {
+		t.Fatalf("got %d records by tag, want the tagged one", len(records))
+	}
+
+	if err := svc.SetRecordTags(context.Background(), userID, record.ID, []string{"personal"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if records, _ := svc.GetByTag(context.Background(), userID, "work"); len(records) != 0 {
+		t.Error("record still listed under replaced tag")
+	}
+	if records, _ := svc.GetByTag(context.Background(), userID, "personal"); len(records) != 1 {
+		t.Error("record not listed under new tag")
+	}
+}
+
+func TestRecordTags_ValidatesLimits(t *testing.T) {
+	userID := uuid.New()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxTagsPerRecord: 2, MaxTagLength: 4}
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	params := CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}
+	var apiErr *apiErrors.APIError
+
+	params.Tags = []string{"a", "b", "c"}
+	if _, _, err := svc.CreateRecord(context.Background(), userID, params); !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument for too many tags", err)
+	}
+	params.Tags = []string{"toolong"}
+	if _, _, err := svc.CreateRecord(context.Background(), userID, params); !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument for oversized tag", err)
+	}
+	params.Tags = []string{""}
+	if _, _, err := svc.CreateRecord(context.Background(), userID, params); !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument for empty tag", err)
+	}
+}
+
+func TestCreateRecord_EnforcesRecordCountQuota(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxRecordsPerUser: 1}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	params := CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("ciphertext"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}
+	first, _, err := svc.CreateRecord(context.Background(), userID, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _, err = svc.CreateRecord(context.Background(), userID, params)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.ResourceExhausted {
+		t.Fatalf("err = %v, want ResourceExhausted at the quota", err)
+	}
+
+	// Soft-deleted records free up quota.
+	if err := svc.DeleteRecord(context.Background(), userID, first.ID, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.CreateRecord(context.Background(), userID, params); err != nil {
+		t.Fatalf("unexpected error after delete: %v", err)
+	}
+}
+
+func TestCreateRecordStream_ResolvesChunkSizeBounds(t *testing.T) {
+	userID := uuid.New()
+	limits := RecordLimits{
+		MaxNameLength:        255,
+		MaxDescriptionLength: 4096,
+		DefaultChunkSize:     8,
+		MinChunkSize:         4,
+		MaxUploadChunkSize:   16,
+	}
+
+	tests := []struct {
+		name      string
+		chunkSize int64
+		want      int64
+		wantErr   bool
+	}{
+		{name: "omitted takes default", chunkSize: 0, want: 8},
+		{name: "oversized is clamped", chunkSize: 64, want: 16},
+		{name: "within bounds kept", chunkSize: 12, want: 12},
+		{name: "below minimum rejected", chunkSize: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+			chunks := &sliceChunkReceiver{chunks: [][]byte{[]byte("data")}}
+			record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+				Type:         model.RecordTypeBinary,
+				Name:         "file",
+				EncryptedKey: []byte("key"),
+				Alg:          "aes-256-gcm",
+				ChunkSize:    tt.chunkSize,
+			}, chunks)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if record.EncryptedChunkSize != tt.want {
+				t.Errorf("stored chunk size %d, want %d", record.EncryptedChunkSize, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRecord_RejectsPayloadAboveUnaryLimit(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxUnaryResponseSize: 8}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	recordID := uuid.New()
+	store.records[recordID] = &model.Record{
+		ID:            recordID,
+		OwnerID:       userID,
+		Type:          model.RecordTypeNote,
+		Name:          "big note",
+		EncryptedData: bytes.Repeat([]byte("x"), 16),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}
+
+	_, err := svc.GetRecord(context.Background(), userID, recordID, false)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.GRPCCode != codes.FailedPrecondition {
+		t.Errorf("code = %s, want %s", apiErr.GRPCCode, codes.FailedPrecondition)
+	}
+	if !strings.Contains(apiErr.Message, "GetRecordStream") {
+		t.Errorf("message %q should point at the streaming path", apiErr.Message)
+	}
+}
+
+func TestRecordPinned_SetAndFilter(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	pinned, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "pinned note",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		Pinned:       true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "plain note",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := svc.GetPinnedRecords(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != pinned.ID {
+		t.Fatalf("expected only the pinned record, got %d records", len(records))
+	}
+
+	// Toggling the flag must bump the version so delta sync picks it up.
+	before := store.records[other.ID].Version
+	if err := svc.SetRecordPinned(context.Background(), userID, other.ID, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.records[other.ID].Version != before+1 {
+		t.Errorf("version = %d, want %d", store.records[other.ID].Version, before+1)
+	}
+	records, err = svc.GetPinnedRecords(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("expected 2 pinned records, got %d", len(records))
+	}
+
+	if err := svc.SetRecordPinned(context.Background(), uuid.New(), pinned.ID, false); err == nil {
+		t.Error("expected error for non-owner pin change")
+	}
+}
+
+func TestCreateRecordStream_AppliesKeyPrefix(t *testing.T) {
+	userID := uuid.New()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}
+	// A prefix without the trailing slash is normalized to one.
+	svc := NewRecord(newMockRecordStore(), newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "staging", false, nil, logger.NewNoop())
+
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    8,
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("data")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantKey := "staging/" + userID.String() + "/" + record.ID.String()
+	if record.S3Key != wantKey {
+		t.Errorf("s3 key = %q, want %q", record.S3Key, wantKey)
+	}
+	if _, ok := storage.objects[wantKey]; !ok {
+		t.Errorf("object not stored under prefixed key %q", wantKey)
+	}
+}
+
+// cancellingRecordStore cancels the context during the updated-records query
+// and tracks whether the tombstone query still ran afterwards.
+type cancellingRecordStore struct {
+	*mockRecordStore
+	cancel            context.CancelFunc
+	deletedAfterCalls int
+}
+
+func (m *cancellingRecordStore) GetUpdatedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]*model.Record, error) {
+	m.cancel()
+	return m.mockRecordStore.GetUpdatedAfter(ctx, userID, after)
+}
+
+func (m *cancellingRecordStore) GetDeletedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]model.Tombstone, error) {
+	m.deletedAfterCalls++
+	return m.mockRecordStore.GetDeletedAfter(ctx, userID, after)
+}
+
+func TestListRecordsDelta_StopsAfterCancellation(t *testing.T) {
+	userID := uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &cancellingRecordStore{mockRecordStore: newMockRecordStore(), cancel: cancel}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), RecordLimits{}, "", false, nil, logger.NewNoop())
+
+	_, _, err := svc.ListRecordsDelta(ctx, userID, time.Unix(0, 0), true, time.Time{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if store.deletedAfterCalls != 0 {
+		t.Errorf("tombstone query ran %d times after cancellation, want 0", store.deletedAfterCalls)
+	}
+}
+
+func TestStreamRecordToClient_DetectsTruncatedObject(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := newRecordService(store, newMockUserStore(userID), storage)
+
+	record := &model.Record{
+		ID:                 uuid.New(),
+		OwnerID:            userID,
+		Type:               model.RecordTypeBinary,
+		Name:               "truncated",
+		Alg:                "aes-256-gcm",
+		S3Key:              "key",
+		EncryptedChunkSize: 8,
+		FileSize:           10,
+	}
+	if _, _, err := store.Create(context.Background(), record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The object lost its tail relative to the size recorded at upload.
+	storage.objects["key"] = []byte("0123456")
+
+	err := svc.StreamRecordToClient(context.Background(), userID, record.ID, &collectingChunkSender{})
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+
+	// A matching size streams cleanly.
+	storage.objects["key"] = []byte("0123456789")
+	if err := svc.StreamRecordToClient(context.Background(), userID, record.ID, &collectingChunkSender{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCreateRecordStream_RecordsMeasuredFileSize(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    8,
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("hello"), []byte("world")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.FileSize != 10 {
+		t.Errorf("file size = %d, want 10", record.FileSize)
+	}
+	if record.ETag == "" {
+		t.Error("etag not recorded at upload")
+	}
+}
+
+func TestCreateRecord_RetryAfterDeleteMakesFreshRecord(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	requestID := uuid.New()
+	params := CreateRecordParams{
+		RequestID:     &requestID,
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("data"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}
+
+	first, wasDuplicate, err := svc.CreateRecord(context.Background(), userID, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wasDuplicate {
+		t.Fatal("first create reported as duplicate")
+	}
+
+	// While the record lives, the same request ID replays it.
+	replayed, wasDuplicate, err := svc.CreateRecord(context.Background(), userID, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !wasDuplicate || replayed.ID != first.ID {
+		t.Fatalf("expected replay of %s, got %s (duplicate=%v)", first.ID, replayed.ID, wasDuplicate)
+	}
+
+	if err := svc.DeleteRecord(context.Background(), userID, first.ID, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// After the delete, the same request ID makes a fresh record instead of
+	// handing back the tombstone.
+	fresh, wasDuplicate, err := svc.CreateRecord(context.Background(), userID, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wasDuplicate {
+		t.Fatal("create after delete reported as duplicate")
+	}
+	if fresh.ID == first.ID {
+		t.Fatal("create after delete returned the deleted record")
+	}
+	if fresh.DeletedAt != nil {
+		t.Fatal("fresh record is marked deleted")
+	}
+}
+
+func TestGetRecord_IncludeDeletedReturnsTombstone(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		EncryptedData: []byte("data"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteRecord(context.Background(), userID, record.ID, 0, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The default keeps masking deleted records as not found.
+	if _, err := svc.GetRecord(context.Background(), userID, record.ID, false); err == nil {
+		t.Fatal("expected not found without include_deleted")
+	}
+
+	got, err := svc.GetRecord(context.Background(), userID, record.ID, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.DeletedAt == nil {
+		t.Error("expected DeletedAt populated on the returned tombstone")
+	}
+
+	// Ownership still applies to tombstones.
+	if _, err := svc.GetRecord(context.Background(), uuid.New(), record.ID, true); err == nil {
+		t.Fatal("expected not found for a foreign tombstone")
+	}
+}
+
+// failingCreateStore rejects every Create so the post-upload failure paths
+// can be exercised.
+type failingCreateStore struct {
+	*mockRecordStore
+}
+
+func (m *failingCreateStore) Create(context.Context, *model.Record) (*model.Record, bool, error) {
+	return nil, false, errors.New("insert record: connection reset")
+}
+
+// faultyDeleteStorage fails Delete, simulating a backend that lost the row
+// cleanup race and left the uploaded object behind.
+type faultyDeleteStorage struct {
+	*mockStorage
+}
+
+func (m *faultyDeleteStorage) Delete(context.Context, string) error {
+	return errors.New("delete object: connection reset")
+}
+
+func TestCreateRecordStream_CreateFailureYieldsTypedError(t *testing.T) {
+	userID := uuid.New()
+	params := CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    8,
+	}
+
+	// Row insert fails but the uploaded object is removed: safe to retry.
+	storage := newMockStorage()
+	svc := NewRecord(&failingCreateStore{newMockRecordStore()}, newMockUserStore(userID), &mockEventStore{}, nil, storage, RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}, "", false, nil, logger.NewNoop())
+	_, _, err := svc.CreateRecordStream(context.Background(), userID, params, &sliceChunkReceiver{chunks: [][]byte{[]byte("data")}})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonUploadNotRecorded {
+		t.Fatalf("expected %s, got %v", apiErrors.ReasonUploadNotRecorded, err)
+	}
+	if len(storage.objects) != 0 {
+		t.Errorf("expected uploaded object cleaned up, %d left", len(storage.objects))
+	}
+
+	// Cleanup fails too: the client learns an orphan object may remain.
+	svc = NewRecord(&failingCreateStore{newMockRecordStore()}, newMockUserStore(userID), &mockEventStore{}, nil, &faultyDeleteStorage{newMockStorage()}, RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096}, "", false, nil, logger.NewNoop())
+	_, _, err = svc.CreateRecordStream(context.Background(), userID, params, &sliceChunkReceiver{chunks: [][]byte{[]byte("data")}})
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonUploadOrphaned {
+		t.Fatalf("expected %s, got %v", apiErrors.ReasonUploadOrphaned, err)
+	}
+}
+
+func TestListRecordIDs_ReturnsLiveIDsOnly(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	var kept uuid.UUID
+	for i := 0; i < 2; i++ {
+		record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+			Type:          model.RecordTypeNote,
+			Name:          "note",
+			EncryptedData: []byte("data"),
+			EncryptedKey:  []byte("key"),
+			Alg:           "aes-256-gcm",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if i == 0 {
+			kept = record.ID
+		} else if err := svc.DeleteRecord(context.Background(), userID, record.ID, 0, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	ids, err := svc.ListRecordIDs(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != kept {
+		t.Fatalf("expected only the live record id %s, got %v", kept, ids)
+	}
+}
+
+func TestCreateRecord_SanitizesDescriptionWhenEnabled(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, SanitizeDescriptions: true}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	record, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		Description:   "line\nnext\x00<script>alert(1)</script>",
+		EncryptedData: []byte("data"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "line\nnext&lt;script&gt;alert(1)&lt;/script&gt;"
+	if record.Description != want {
+		t.Errorf("description = %q, want %q", record.Description, want)
+	}
+
+	// Invalid UTF-8 is rejected whether or not the sanitizer is on.
+	for _, s := range []*Record{svc, newRecordService(store, newMockUserStore(userID), newMockStorage())} {
+		_, _, err := s.CreateRecord(context.Background(), userID, CreateRecordParams{
+			Type:          model.RecordTypeNote,
+			Name:          "note",
+			Description:   string([]byte{0xff, 0xfe}),
+			EncryptedData: []byte("data"),
+			EncryptedKey:  []byte("key"),
+			Alg:           "aes-256-gcm",
+		})
+		var apiErr *apiErrors.APIError
+		if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidMetadata {
+			t.Errorf("err = %v, want invalid metadata for bad utf-8", err)
+		}
+	}
+}
+
+func TestListEndpoints_EmptyResultsAreNonNil(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	records, err := svc.GetByUserID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if records == nil {
+		t.Error("GetByUserID returned nil slice for empty account")
+	}
+
+	records, tombstones, err := svc.ListRecordsDelta(context.Background(), userID, time.Unix(0, 0), true, time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if records == nil || tombstones == nil {
+		t.Errorf("delta returned nil slices: records=%v tombstones=%v", records, tombstones)
+	}
+
+	ids, err := svc.ListRecordIDs(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids == nil {
+		t.Error("ListRecordIDs returned nil slice for empty account")
+	}
+}
+
+func TestChangeRecordType(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	note := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeNote, UpdatedAt: time.Now().Add(-time.Hour)}
+	binary := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeBinary, S3Key: "key"}
+	store.records[note.ID] = note
+	store.records[binary.ID] = binary
+
+	before := time.Now().Add(-time.Minute)
+	if err := svc.ChangeRecordType(context.Background(), userID, note.ID, model.RecordTypeLogin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if note.Type != model.RecordTypeLogin {
+		t.Errorf("type = %s, want %s", note.Type, model.RecordTypeLogin)
+	}
+	// The bumped updated_at makes the change visible to delta sync.
+	records, _, err := svc.ListRecordsDelta(context.Background(), userID, before, false, time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != note.ID {
+		t.Errorf("delta = %v, want the converted record", records)
+	}
+
+	var apiErr *apiErrors.APIError
+	err = svc.ChangeRecordType(context.Background(), userID, note.ID, model.RecordTypeBinary)
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonTypeChangeDenied {
+		t.Errorf("to binary: err = %v, want type change denied", err)
+	}
+	err = svc.ChangeRecordType(context.Background(), userID, binary.ID, model.RecordTypeNote)
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonTypeChangeDenied {
+		t.Errorf("from binary: err = %v, want type change denied", err)
+	}
+	err = svc.ChangeRecordType(context.Background(), userID, note.ID, model.RecordType("bogus"))
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidRecordType {
+		t.Errorf("bogus type: err = %v, want invalid record type", err)
+	}
+	err = svc.ChangeRecordType(context.Background(), uuid.New(), note.ID, model.RecordTypeCard)
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonRecordNotFound {
+		t.Errorf("foreign user: err = %v, want not found", err)
+	}
+
+	// Converting to the current type is a no-op, not an error.
+	version := note.Version
+	if err := svc.ChangeRecordType(context.Background(), userID, note.ID, model.RecordTypeLogin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if note.Version != version {
+		t.Error("no-op conversion must not bump the version")
+	}
+}
+
+func TestCreateRecordStream_InlinesSmallNote(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxInlineStreamPayload: 16}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	chunks := &sliceChunkReceiver{chunks: [][]byte{[]byte("cipher"), []byte("text")}}
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "long note",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+	}, chunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(record.EncryptedData, []byte("ciphertext")) {
+		t.Errorf("payload = %q, want reassembled chunks inline", record.EncryptedData)
+	}
+	if record.S3Key != "" || len(storage.objects) != 0 {
+		t.Error("payload under the cap must not be promoted to object storage")
+	}
+	if record.EncryptedChunkSize != 0 {
+		t.Errorf("chunk size = %d, want 0 for an inline record", record.EncryptedChunkSize)
+	}
+}
+
+func TestCreateRecordStream_PromotesOversizedNote(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, MaxInlineStreamPayload: 8}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	payload := []byte("a payload well past the inline cap")
+	chunks := &sliceChunkReceiver{chunks: [][]byte{payload[:20], payload[20:]}}
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "huge note",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+	}, chunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.S3Key == "" {
+		t.Fatal("payload over the cap must be promoted to object storage")
+	}
+	if !bytes.Equal(storage.objects[record.S3Key], payload) {
+		t.Errorf("stored object = %q, want the full payload", storage.objects[record.S3Key])
+	}
+	if len(record.EncryptedData) != 0 {
+		t.Error("promoted record must not keep an inline payload")
+	}
+	if record.EncryptedChunkSize <= 0 {
+		t.Errorf("chunk size = %d, want a positive transport chunking", record.EncryptedChunkSize)
+	}
+	if record.FileSize != int64(len(payload)) {
+		t.Errorf("file size = %d, want %d", record.FileSize, len(payload))
+	}
+
+	// The promoted record downloads through the same streaming path as a
+	// binary payload.
+	sender := &collectingChunkSender{}
+	if err := svc.StreamRecordToClient(context.Background(), userID, record.ID, sender); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []byte
+	for _, chunk := range sender.chunks {
+		got = append(got, chunk...)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("streamed payload = %q, want %q", got, payload)
+	}
+}
+
+func TestCreateRecordStream_NonBinaryRejectedWhenCapUnset(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	_, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeNote,
+		Name:         "note",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("x")}})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidMetadata {
+		t.Errorf("err = %v, want invalid metadata while the cap is unset", err)
+	}
+}
+
+func TestStreamRecordToClient_ServesInlineNote(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	payload := []byte("an inline note payload")
+	record := &model.Record{
+		ID:            uuid.New(),
+		OwnerID:       userID,
+		Type:          model.RecordTypeNote,
+		Name:          "note",
+		Alg:           "aes-256-gcm",
+		EncryptedData: payload,
+	}
+	store.records[record.ID] = record
+
+	sender := &collectingChunkSender{}
+	if err := svc.StreamRecordToClient(context.Background(), userID, record.ID, sender); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []byte
+	for _, chunk := range sender.chunks {
+		got = append(got, chunk...)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("streamed payload = %q, want %q", got, payload)
+	}
+}
+
+// misreportingStorage reports a wrong size from Stat, simulating a backend
+// that acked a put it did not durably store.
+type misreportingStorage struct {
+	*mockStorage
+}
+
+func (s *misreportingStorage) Stat(_ context.Context, _ string) (int64, error) {
+	return 1, nil
+}
+
+func TestCreateRecordStream_VerifyUploadsRejectsSizeMismatch(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := &misreportingStorage{mockStorage: newMockStorage()}
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, VerifyUploads: true}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	_, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("aaaa"), []byte("bb")}})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonUploadFailed {
+		t.Fatalf("err = %v, want upload failed", err)
+	}
+	if len(store.created) != 0 {
+		t.Error("no record row must be committed on failed verification")
+	}
+	if len(storage.objects) != 0 {
+		t.Error("the unverifiable object must be removed")
+	}
+}
+
+func TestCreateRecordStream_VerifyUploadsPassesOnMatch(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	limits := RecordLimits{MaxNameLength: 255, MaxDescriptionLength: 4096, VerifyUploads: true}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage, limits, "", false, nil, logger.NewNoop())
+
+	record, _, err := svc.CreateRecordStream(context.Background(), userID, CreateRecordParams{
+		Type:         model.RecordTypeBinary,
+		Name:         "file",
+		EncryptedKey: []byte("key"),
+		Alg:          "aes-256-gcm",
+		ChunkSize:    4,
+	}, &sliceChunkReceiver{chunks: [][]byte{[]byte("aaaa"), []byte("bb")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(storage.objects[record.S3Key]) != "aaaabb" {
+		t.Errorf("stored %q, want %q", storage.objects[record.S3Key], "aaaabb")
+	}
+}
+
+func TestAcknowledgeTombstones_PurgesOnlyFullyAckedTombstones(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	acks := newMockAckStore()
+	limits := RecordLimits{TombstoneAckWindow: time.Hour}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, acks, newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	deletedAt := time.Now().Add(-2 * time.Hour)
+	record := &model.Record{ID: uuid.New(), OwnerID: userID, DeletedAt: &deletedAt}
+	store.records[record.ID] = record
+
+	// One device acknowledged only up to before the deletion, the other has
+	// caught up: the laggard holds the high-water mark below the tombstone.
+	if err := svc.AcknowledgeTombstones(context.Background(), userID, "laptop", deletedAt.Add(-time.Minute)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.AcknowledgeTombstones(context.Background(), userID, "phone", time.Now().Add(-90*time.Minute)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := store.records[record.ID]; !ok {
+		t.Fatal("tombstone purged before every device acknowledged it")
+	}
+
+	// The laggard catches up; every acknowledging device has now seen it.
+	if err := svc.AcknowledgeTombstones(context.Background(), userID, "laptop", time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := store.records[record.ID]; ok {
+		t.Error("tombstone not purged after all devices acknowledged it")
+	}
+}
+
+func TestAcknowledgeTombstones_KeepsSafetyWindow(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	limits := RecordLimits{TombstoneAckWindow: time.Hour}
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, newMockAckStore(), newMockStorage(), limits, "", false, nil, logger.NewNoop())
+
+	deletedAt := time.Now().Add(-time.Minute)
+	record := &model.Record{ID: uuid.New(), OwnerID: userID, DeletedAt: &deletedAt}
+	store.records[record.ID] = record
+
+	// Acknowledged, but younger than the window: a device that has not synced
+	// recently must still find the tombstone on its next delta.
+	if err := svc.AcknowledgeTombstones(context.Background(), userID, "phone", time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := store.records[record.ID]; !ok {
+		t.Error("tombstone inside the safety window purged")
+	}
+}
+
+func TestAcknowledgeTombstones_ZeroWindowRecordsWithoutPurging(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	acks := newMockAckStore()
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, acks, newMockStorage(), RecordLimits{}, "", false, nil, logger.NewNoop())
+
+	deletedAt := time.Now().Add(-48 * time.Hour)
+	record := &model.Record{ID: uuid.New(), OwnerID: userID, DeletedAt: &deletedAt}
+	store.records[record.ID] = record
+
+	if err := svc.AcknowledgeTombstones(context.Background(), userID, "phone", time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := store.records[record.ID]; !ok {
+		t.Error("tombstone purged with early purging disabled")
+	}
+	if len(acks.acks[userID]) != 1 {
+		t.Error("acknowledgment not recorded with early purging disabled")
+	}
+}
+
+func TestListRecordsDelta_ExcludesAckedTombstones(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, newMockAckStore(), newMockStorage(), RecordLimits{}, "", false, nil, logger.NewNoop())
+
+	oldDelete := time.Now().Add(-3 * time.Hour)
+	newDelete := time.Now().Add(-time.Hour)
+	old := &model.Record{ID: uuid.New(), OwnerID: userID, DeletedAt: &oldDelete}
+	recent := &model.Record{ID: uuid.New(), OwnerID: userID, DeletedAt: &newDelete}
+	store.records[old.ID] = old
+	store.records[recent.ID] = recent
+
+	_, tombstones, err := svc.ListRecordsDelta(context.Background(), userID, time.Unix(0, 0), true, time.Now().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tombstones) != 1 || tombstones[0].ID != recent.ID {
+		t.Errorf("tombstones = %v, want only the unacknowledged one", tombstones)
+	}
+}
+
+func TestListRecordsDelta_StaleCursorRequiresFullResync(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(),
+		RecordLimits{MaxSyncLookback: time.Hour}, "", false, nil, logger.NewNoop())
+
+	_, _, err := svc.ListRecordsDelta(context.Background(), userID, time.Now().Add(-2*time.Hour), false, time.Time{})
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonFullResyncRequired {
+		t.Errorf("err = %v, want full-resync error", err)
+	}
+
+	if _, _, err := svc.ListRecordsDelta(context.Background(), userID, time.Now().Add(-time.Minute), false, time.Time{}); err != nil {
+		t.Errorf("fresh cursor: unexpected error: %v", err)
+	}
+	// The epoch cursor is the list-everything sentinel, not a stale delta.
+	if _, _, err := svc.ListRecordsDelta(context.Background(), userID, time.Unix(0, 0), true, time.Time{}); err != nil {
+		t.Errorf("epoch cursor: unexpected error: %v", err)
+	}
+}
+
+func TestImportRecords_CreatesAndResolvesConflicts(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	knownID := uuid.New()
+	server, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		RequestID:     &knownID,
+		Type:          model.RecordTypeNote,
+		Name:          "server copy",
+		EncryptedData: []byte("server"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	freshID := uuid.New()
+	conflict := ImportRecordItem{CreateRecordParams: CreateRecordParams{
+		RequestID:     &knownID,
+		Type:          model.RecordTypeNote,
+		Name:          "client copy",
+		EncryptedData: []byte("client"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}}
+	fresh := ImportRecordItem{CreateRecordParams: CreateRecordParams{
+		RequestID:     &freshID,
+		Type:          model.RecordTypeLogin,
+		Name:          "new on client",
+		EncryptedData: []byte("login"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}}
+
+	results, err := svc.ImportRecords(context.Background(), userID, []ImportRecordItem{conflict, fresh}, ImportServerWins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != ImportStatusSkipped || results[0].RecordID != server.ID {
+		t.Errorf("conflict result = %+v, want skipped %s", results[0], server.ID)
+	}
+	if results[1].Status != ImportStatusCreated {
+		t.Errorf("fresh result = %+v, want created", results[1])
+	}
+	if store.records[server.ID].Name != "server copy" {
+		t.Error("server_wins import overwrote the server row")
+	}
+
+	results, err = svc.ImportRecords(context.Background(), userID, []ImportRecordItem{conflict}, ImportClientWins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != ImportStatusUpdated {
+		t.Errorf("conflict result = %+v, want updated", results[0])
+	}
+	if got := store.records[server.ID]; got.Name != "client copy" || string(got.EncryptedData) != "client" {
+		t.Errorf("server row = %q/%q, want the client copy", got.Name, got.EncryptedData)
+	}
+}
+
+func TestImportRecords_NewestWinsComparesTimestamps(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := newRecordService(store, newMockUserStore(userID), newMockStorage())
+
+	requestID := uuid.New()
+	server, _, err := svc.CreateRecord(context.Background(), userID, CreateRecordParams{
+		RequestID:     &requestID,
+		Type:          model.RecordTypeNote,
+		Name:          "server copy",
+		EncryptedData: []byte("server"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	item := ImportRecordItem{CreateRecordParams: CreateRecordParams{
+		RequestID:     &requestID,
+		Type:          model.RecordTypeNote,
+		Name:          "client copy",
+		EncryptedData: []byte("client"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}, UpdatedAt: store.records[server.ID].UpdatedAt.Add(-time.Hour)}
+
+	results, err := svc.ImportRecords(context.Background(), userID, []ImportRecordItem{item}, ImportNewestWins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != ImportStatusSkipped {
+		t.Errorf("stale client result = %+v, want skipped", results[0])
+	}
+
+	item.UpdatedAt = store.records[server.ID].UpdatedAt.Add(time.Hour)
+	results, err = svc.ImportRecords(context.Background(), userID, []ImportRecordItem{item}, ImportNewestWins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != ImportStatusUpdated {
+		t.Errorf("newer client result = %+v, want updated", results[0])
+	}
+	if store.records[server.ID].Name != "client copy" {
+		t.Error("newer client copy did not overwrite the server row")
+	}
+}
+
+func TestImportRecords_RequiresRequestIDAndKnownStrategy(t *testing.T) {
+	userID := uuid.New()
+	svc := newRecordService(newMockRecordStore(), newMockUserStore(userID), newMockStorage())
+
+	item := ImportRecordItem{CreateRecordParams: CreateRecordParams{
+		Type:          model.RecordTypeNote,
+		Name:          "no request id",
+		EncryptedData: []byte("data"),
+		EncryptedKey:  []byte("key"),
+		Alg:           "aes-256-gcm",
+	}}
+	_, err := svc.ImportRecords(context.Background(), userID, []ImportRecordItem{item}, ImportServerWins)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonInvalidMetadata {
+		t.Fatalf("err = %v, want invalid metadata for a missing request id", err)
+	}
+
+	requestID := uuid.New()
+	item.RequestID = &requestID
+	if _, err := svc.ImportRecords(context.Background(), userID, []ImportRecordItem{item}, ImportStrategy("merge")); err == nil {
+		t.Fatal("expected error for an unknown strategy")
+	}
+}
+
+func TestGetPresignedPutURL_GatedAndObjectBackedOnly(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(),
+		RecordLimits{}, "", false, nil, logger.NewNoop())
+
+	binary := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeBinary, S3Key: "objects/bin"}
+	store.records[binary.ID] = binary
+
+	_, _, err := svc.GetPresignedPutURL(context.Background(), userID, binary.ID)
+	var apiErr *apiErrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonPresignedDisabled {
+		t.Errorf("err = %v, want presigned-disabled error", err)
+	}
+
+	svc = NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(),
+		RecordLimits{PresignedUploads: true}, "", false, nil, logger.NewNoop())
+	url, expiresAt, err := svc.GetPresignedPutURL(context.Background(), userID, binary.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if url == "" || !expiresAt.After(time.Now()) {
+		t.Errorf("url, expiresAt = %q, %v, want a URL expiring in the future", url, expiresAt)
+	}
+
+	inline := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeNote, EncryptedData: []byte("x")}
+	store.records[inline.ID] = inline
+	_, _, err = svc.GetPresignedPutURL(context.Background(), userID, inline.ID)
+	if !errors.As(err, &apiErr) || apiErr.Reason != apiErrors.ReasonNotObjectBacked {
+		t.Errorf("err = %v, want not-object-backed error", err)
+	}
+}
+
+func TestFinalizeBinaryUpdate_ReconcilesMetadataFromStat(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	storage := newMockStorage()
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, storage,
+		RecordLimits{PresignedUploads: true}, "", false, nil, logger.NewNoop())
+
+	record := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeBinary, S3Key: "objects/bin", FileSize: 3, ETag: "stale", Version: 1}
+	store.records[record.ID] = record
+	storage.objects["objects/bin"] = []byte("replaced contents")
+
+	updated, err := svc.FinalizeBinaryUpdate(context.Background(), userID, record.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.FileSize != int64(len("replaced contents")) {
+		t.Errorf("file size = %d, want %d", updated.FileSize, len("replaced contents"))
+	}
+	if updated.ETag == "stale" || store.records[record.ID].ETag == "stale" {
+		t.Error("etag not reconciled from stat")
+	}
+	if store.records[record.ID].Version != 2 {
+		t.Errorf("version = %d, want 2", store.records[record.ID].Version)
+	}
+}
+
+func TestGetStorageUsage_AggregatesLiveRecordsOnly(t *testing.T) {
+	userID := uuid.New()
+	store := newMockRecordStore()
+	svc := NewRecord(store, newMockUserStore(userID), &mockEventStore{}, nil, newMockStorage(),
+		RecordLimits{MaxRecordsPerUser: 50}, "", false, nil, logger.NewNoop())
+
+	note := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeNote, EncryptedData: []byte("0123456789")}
+	binary := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeBinary, S3Key: "objects/bin", FileSize: 100}
+	deletedAt := time.Now()
+	deleted := &model.Record{ID: uuid.New(), OwnerID: userID, Type: model.RecordTypeNote, EncryptedData: []byte("gone"), DeletedAt: &deletedAt}
+	foreign := &model.Record{ID: uuid.New(), OwnerID: uuid.New(), Type: model.RecordTypeNote, EncryptedData: []byte("other")}
+	for _, r := range []*model.Record{note, binary, deleted, foreign} {
+		store.records[r.ID] = r
+	}
+
+	report, err := svc.GetStorageUsage(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.TotalBytes != 110 {
+		t.Errorf("total bytes = %d, want 110", report.TotalBytes)
+	}
+	if report.TotalRecords != 2 {
+		t.Errorf("total records = %d, want 2", report.TotalRecords)
+	}
+	if report.CountByType[model.RecordTypeNote] != 1 || report.CountByType[model.RecordTypeBinary] != 1 {
+		t.Errorf("count by type = %v, want one note and one binary", report.CountByType)
+	}
+	if report.MaxRecords != 50 {
+		t.Errorf("max records = %d, want 50", report.MaxRecords)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	apiErrors "github.com/dtroode/gophkeeper-server/internal/apierrors"
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// Claims are the JWT claims carried by access tokens. Scopes limit which
+// methods the token may call; an empty list marks a legacy full-access token.
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID uuid.UUID `json:"uid"`
+	Scopes []string  `json:"scopes,omitempty"`
+}
+
+// TokenPair is an issued access/refresh token pair.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// TokenService issues, refreshes and revokes session tokens.
+type TokenService struct {
+	tokenStore model.RefreshTokenStore
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	maxActive  int
+	// leeway tolerates clock skew between the issuing and the validating
+	// host on every expiry comparison, so tokens issued elsewhere are not
+	// spuriously rejected right at the boundary. Keep it small — it extends
+	// every token's effective lifetime by the same amount.
+	leeway time.Duration
+	logger *logger.Logger
+}
+
+// NewTokenService creates a token service. maxActive caps the number of
+// active refresh tokens per user; zero disables the cap. leeway is the clock
+// skew tolerated on expiry checks; zero compares against the local clock
+// exactly.
+func NewTokenService(
+	tokenStore model.RefreshTokenStore,
+	secret []byte,
+	accessTTL, refreshTTL time.Duration,
+	maxActive int,
+	leeway time.Duration,
+	l *logger.Logger,
+) *TokenService {
+	return &TokenService{
+		tokenStore: tokenStore,
+		secret:     secret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		maxActive:  maxActive,
+		leeway:     leeway,
+		logger:     l,
+	}
+}
+
+// Issue creates a new access/refresh token pair for the user. The scopes are
+// embedded in the access token and remembered on the refresh token so rotation
+// cannot widen access; nil scopes issue a full-access token.
+func (s *TokenService) Issue(ctx context.Context, userID uuid.UUID, scopes []string) (*TokenPair, error) {
+	now := time.Now()
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+		UserID: userID,
+		Scopes: scopes,
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return nil, fmt.Errorf("sign access token: %w", err)
+	}
+
+	if err := s.evictOverLimit(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	tokenID := uuid.New()
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate refresh secret: %w", err)
+	}
+	hash := sha256.Sum256(secret)
+
+	record := &model.RefreshToken{
+		ID:        tokenID,
+		UserID:    userID,
+		TokenHash: hash[:],
+		Scopes:    scopes,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}
+	if err := s.tokenStore.Create(ctx, record); err != nil {
+		return nil, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	refresh := tokenID.String() + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return &TokenPair{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// evictOverLimit revokes the user's oldest active refresh tokens so that one
+// more can be issued without exceeding the configured cap. Eviction is logged
+// so operators can spot clients that never rotate.
+func (s *TokenService) evictOverLimit(ctx context.Context, userID uuid.UUID) error {
+	if s.maxActive <= 0 {
+		return nil
+	}
+	active, err := s.tokenStore.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("list active refresh tokens: %w", err)
+	}
+	excess := len(active) - s.maxActive + 1
+	if excess <= 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, token := range active[:excess] {
+		if err := s.tokenStore.Revoke(ctx, token.ID, now); err != nil {
+			return fmt.Errorf("evict refresh token: %w", err)
+		}
+	}
+	s.logger.Warn("evicted oldest refresh tokens over per-user limit",
+		"user_id", userID,
+		"evicted", excess,
+		"limit", s.maxActive,
+	)
+	return nil
+}
+
+// Refresh rotates a refresh token: the presented token is revoked and a new
+// pair is issued.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	record, err := s.lookup(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRecord(record); err != nil {
+		return nil, err
+	}
+	if err := s.tokenStore.Revoke(ctx, record.ID, time.Now()); err != nil {
+		return nil, fmt.Errorf("revoke refresh token: %w", err)
+	}
+	return s.Issue(ctx, record.UserID, record.Scopes)
+}
+
+// Validate parses and verifies an access token, returning the user ID and the
+// token's scopes.
+func (s *TokenService) Validate(ctx context.Context, accessToken string) (uuid.UUID, []string, error) {
+	return s.ValidateWithGrace(ctx, accessToken, 0)
+}
+
+// ValidateWithGrace is Validate with an expiry grace: a token expired no
+// longer than grace ago is still accepted. It exists for long-lived streams
+// whose clients cannot refresh credentials mid-stream; the trade-off is that
+// a stolen token stays usable on those methods for the grace past its expiry,
+// so the grace should stay well below the access-token TTL.
+func (s *TokenService) ValidateWithGrace(_ context.Context, accessToken string, grace time.Duration) (uuid.UUID, []string, error) {
+	claims := &Claims{}
+	var opts []jwt.ParserOption
+	// The skew leeway applies to every validation; the stream grace stacks
+	// on top of it for the methods that ask for one.
+	if total := grace + s.leeway; total > 0 {
+		opts = append(opts, jwt.WithLeeway(total))
+	}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return s.secret, nil
+	}, opts...)
+	if err != nil {
+		return uuid.Nil, nil, apiErrors.NewErrTokenExpired()
+	}
+	return claims.UserID, claims.Scopes, nil
+}
+
+// RevokeAllForUser revokes every active refresh token belonging to the user.
+func (s *TokenService) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	if err := s.tokenStore.RevokeAllForUser(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("revoke tokens: %w", err)
+	}
+	return nil
+}
+
+// lookup parses a refresh token string and fetches the matching stored record,
+// verifying the secret against the stored hash.
+func (s *TokenService) lookup(ctx context.Context, refreshToken string) (*model.RefreshToken, error) {
+	idPart, secretPart, found := strings.Cut(refreshToken, ".")
+	if !found {
+		return nil, apiErrors.NewErrInvalidCredentials()
+	}
+	tokenID, err := uuid.Parse(idPart)
+	if err != nil {
+		return nil, apiErrors.NewErrInvalidCredentials()
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(secretPart)
+	if err != nil {
+		return nil, apiErrors.NewErrInvalidCredentials()
+	}
+	record, err := s.tokenStore.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+	if record == nil {
+		return nil, apiErrors.NewErrInvalidCredentials()
+	}
+	hash := sha256.Sum256(secret)
+	if subtle.ConstantTimeCompare(hash[:], record.TokenHash) != 1 {
+		return nil, apiErrors.NewErrInvalidCredentials()
+	}
+	return record, nil
+}
+
+// validateRecord checks a stored refresh token for expiry and revocation. The
+// expiry comparison tolerates the configured clock-skew leeway, matching the
+// access-token check.
+func (s *TokenService) validateRecord(record *model.RefreshToken) error {
+	if record.RevokedAt != nil {
+		return apiErrors.NewErrTokenRevoked()
+	}
+	if time.Now().After(record.ExpiresAt.Add(s.leeway)) {
+		return apiErrors.NewErrTokenExpired()
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+func TestIssue_EvictsOldestWhenOverLimit(t *testing.T) {
+	store := newMockTokenStore()
+	svc := NewTokenService(store, []byte("secret"), time.Minute, time.Hour, 2, 0, logger.NewNoop())
+	userID := uuid.New()
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Issue(context.Background(), userID, nil); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		// Issued-at ordering must be strict for the mock's sort.
+		time.Sleep(time.Millisecond)
+	}
+
+	active, err := store.ListActiveByUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("ListActiveByUser: %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("active tokens = %d, want 2", len(active))
+	}
+}
+
+func TestIssue_NoEvictionWhenUnlimited(t *testing.T) {
+	store := newMockTokenStore()
+	svc := NewTokenService(store, []byte("secret"), time.Minute, time.Hour, 0, 0, logger.NewNoop())
+	userID := uuid.New()
+
+	for i := 0; i < 5; i++ {
+		if _, err := svc.Issue(context.Background(), userID, nil); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+	}
+
+	active, err := store.ListActiveByUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("ListActiveByUser: %v", err)
+	}
+	if len(active) != 5 {
+		t.Errorf("active tokens = %d, want 5", len(active))
+	}
+}
+
+func TestValidate_LeewayToleratesClockSkew(t *testing.T) {
+	store := newMockTokenStore()
+	// A negative access TTL issues tokens that are already expired, standing
+	// in for an issuing host whose clock runs ahead of ours.
+	skewed := NewTokenService(store, []byte("secret"), -10*time.Second, time.Hour, 0, 30*time.Second, logger.NewNoop())
+	pair, err := skewed.Issue(context.Background(), uuid.New(), nil)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, _, err := skewed.Validate(context.Background(), pair.AccessToken); err != nil {
+		t.Errorf("leeway 30s: unexpected error: %v", err)
+	}
+
+	strict := NewTokenService(store, []byte("secret"), -10*time.Second, time.Hour, 0, 0, logger.NewNoop())
+	if _, _, err := strict.Validate(context.Background(), pair.AccessToken); err == nil {
+		t.Error("leeway 0: expected expired token to be rejected")
+	}
+}
+
+func TestRefresh_LeewayToleratesClockSkew(t *testing.T) {
+	store := newMockTokenStore()
+	skewed := NewTokenService(store, []byte("secret"), time.Minute, -10*time.Second, 0, 30*time.Second, logger.NewNoop())
+	pair, err := skewed.Issue(context.Background(), uuid.New(), nil)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := skewed.Refresh(context.Background(), pair.RefreshToken); err != nil {
+		t.Errorf("leeway 30s: unexpected error: %v", err)
+	}
+
+	strict := NewTokenService(store, []byte("secret"), time.Minute, -10*time.Second, 0, 0, logger.NewNoop())
+	pair, err = strict.Issue(context.Background(), uuid.New(), nil)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := strict.Refresh(context.Background(), pair.RefreshToken); err == nil {
+		t.Error("leeway 0: expected expired refresh token to be rejected")
+	}
+}
@@ -0,0 +1,296 @@
+// Package minio implements the object storage on top of a MinIO/S3 backend.
+package minio
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+)
+
+// Client stores binary record payloads in one or more MinIO buckets. With a
+// single bucket every object lands there; with several, objects are sharded by
+// hashing the user segment of the key so one user's objects always share a
+// bucket.
+type Client struct {
+	mc        *miniogo.Client
+	buckets   []string
+	opTimeout time.Duration
+	logger    *logger.Logger
+}
+
+// New connects to MinIO and makes sure every configured bucket exists.
+// opTimeout bounds each storage operation with its own deadline so a stalled
+// backend fails fast instead of consuming the whole request budget; zero
+// leaves operations on the caller's deadline alone.
+func New(ctx context.Context, endpoint, accessKey, secretKey string, buckets []string, useSSL bool, opTimeout time.Duration, l *logger.Logger) (*Client, error) {
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("at least one bucket is required")
+	}
+	mc, err := miniogo.New(endpoint, &miniogo.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create minio client: %w", err)
+	}
+
+	client := &Client{mc: mc, buckets: buckets, opTimeout: opTimeout, logger: l}
+	for _, bucket := range buckets {
+		if err := client.ensureBucketExists(ctx, bucket); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// ensureBucketExists creates the bucket when it is missing.
+func (c *Client) ensureBucketExists(ctx context.Context, bucket string) error {
+	exists, err := c.mc.BucketExists(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("check bucket: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if err := c.mc.MakeBucket(ctx, bucket, miniogo.MakeBucketOptions{}); err != nil {
+		return fmt.Errorf("make bucket: %w", err)
+	}
+	c.logger.Info("created bucket", "bucket", bucket)
+	return nil
+}
+
+// CheckBuckets verifies the backend is reachable and every configured bucket
+// exists, creating nothing. It backs the startup dependency checks.
+func (c *Client) CheckBuckets(ctx context.Context) error {
+	for _, bucket := range c.buckets {
+		exists, err := c.mc.BucketExists(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("check bucket %s: %w", bucket, err)
+		}
+		if !exists {
+			return fmt.Errorf("bucket %s does not exist", bucket)
+		}
+	}
+	return nil
+}
+
+// ApplyLifecycle sets an expiration rule on every configured bucket: objects
+// under the prefix are expired after the given number of days. Setting the
+// rule overwrites any previous one, so repeated application is idempotent.
+// Backends without lifecycle support are logged and skipped rather than
+// failing startup.
+func (c *Client) ApplyLifecycle(ctx context.Context, prefix string, days int) error {
+	config := lifecycle.NewConfiguration()
+	config.Rules = []lifecycle.Rule{
+		{
+			ID:     "gophkeeper-expire",
+			Status: "Enabled",
+			RuleFilter: lifecycle.Filter{
+				Prefix: prefix,
+			},
+			Expiration: lifecycle.Expiration{
+				Days: lifecycle.ExpirationDays(days),
+			},
+		},
+	}
+	for _, bucket := range c.buckets {
+		if err := c.mc.SetBucketLifecycle(ctx, bucket, config); err != nil {
+			resp := miniogo.ToErrorResponse(err)
+			if resp.Code == "NotImplemented" {
+				c.logger.Warn("backend does not support lifecycle rules, skipping", "bucket", bucket)
+				return nil
+			}
+			return fmt.Errorf("set bucket lifecycle: %w", err)
+		}
+		c.logger.Info("applied lifecycle rule", "bucket", bucket, "prefix", prefix, "days", days)
+	}
+	return nil
+}
+
+// bucketForKey selects the bucket for an object key. Keys are laid out as
+// [<env prefix>/]<user id>/<record id>, so hashing the segment before the
+// record id keeps each user's objects in one bucket, with or without an
+// environment prefix.
+func (c *Client) bucketForKey(key string) string {
+	if len(c.buckets) == 1 {
+		return c.buckets[0]
+	}
+	parts := strings.Split(key, "/")
+	userSegment := parts[0]
+	if len(parts) >= 2 {
+		userSegment = parts[len(parts)-2]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(userSegment))
+	return c.buckets[h.Sum32()%uint32(len(c.buckets))]
+}
+
+// opContext derives the per-operation context. With no timeout configured the
+// caller's context is returned with a no-op cancel.
+func (c *Client) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.opTimeout)
+}
+
+// Upload stores an object under the given key and returns the ETag MinIO
+// computed for it. Pass size -1 when the total length is unknown.
+func (c *Client) Upload(ctx context.Context, key string, data io.Reader, size int64) (string, error) {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	info, err := c.mc.PutObject(ctx, c.bucketForKey(key), key, data, size, miniogo.PutObjectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("put object: %w", err)
+	}
+	return info.ETag, nil
+}
+
+// Download returns a reader over the object stored under the key. The
+// operation deadline covers the whole read, not each chunk: the derived
+// context is cancelled when the returned reader is closed, and its fixed
+// deadline bounds the total download.
+func (c *Client) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, cancel := c.opContext(ctx)
+	object, err := c.mc.GetObject(ctx, c.bucketForKey(key), key, miniogo.GetObjectOptions{})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+	return &cancelReadCloser{ReadCloser: object, cancel: cancel}, nil
+}
+
+// Delete removes the object stored under the key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	if err := c.mc.RemoveObject(ctx, c.bucketForKey(key), key, miniogo.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("remove object: %w", err)
+	}
+	return nil
+}
+
+// List returns up to limit object keys lexically greater than afterKey, in
+// lexical order. Buckets shard by user hash, not key order, so every bucket
+// is listed and the results are merged before sorting. ListObjects yields
+// each bucket's keys in lexical order, so a bucket's listing stops once limit
+// keys are collected from it: anything later in that bucket cannot displace a
+// collected key after the merge.
+func (c *Client) List(ctx context.Context, afterKey string, limit int) ([]string, error) {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	var keys []string
+	for _, bucket := range c.buckets {
+		opts := miniogo.ListObjectsOptions{Recursive: true, StartAfter: afterKey}
+		bucketCtx, stop := context.WithCancel(ctx)
+		taken := 0
+		for object := range c.mc.ListObjects(bucketCtx, bucket, opts) {
+			if object.Err != nil {
+				stop()
+				return nil, fmt.Errorf("list objects: %w", object.Err)
+			}
+			if object.Key <= afterKey {
+				continue
+			}
+			keys = append(keys, object.Key)
+			taken++
+			if limit > 0 && taken >= limit {
+				break
+			}
+		}
+		stop()
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+// Copy rewrites the object onto itself with a server-side copy, so the stored
+// copy is re-written under the bucket's current encryption settings.
+func (c *Client) Copy(ctx context.Context, key string) error {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	bucket := c.bucketForKey(key)
+	_, err := c.mc.CopyObject(ctx,
+		miniogo.CopyDestOptions{Bucket: bucket, Object: key, ReplaceMetadata: true},
+		miniogo.CopySrcOptions{Bucket: bucket, Object: key},
+	)
+	if err != nil {
+		return fmt.Errorf("copy object: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether an object is stored under the key.
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	_, err := c.mc.StatObject(ctx, c.bucketForKey(key), key, miniogo.StatObjectOptions{})
+	if err != nil {
+		resp := miniogo.ToErrorResponse(err)
+		if resp.Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat object: %w", err)
+	}
+	return true, nil
+}
+
+// Stat returns the stored object's size in bytes.
+func (c *Client) Stat(ctx context.Context, key string) (int64, error) {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	info, err := c.mc.StatObject(ctx, c.bucketForKey(key), key, miniogo.StatObjectOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("stat object: %w", err)
+	}
+	return info.Size, nil
+}
+
+// StatETag returns the stored object's size and ETag.
+func (c *Client) StatETag(ctx context.Context, key string) (int64, string, error) {
+	ctx, cancel := c.opContext(ctx)
+	defer cancel()
+	info, err := c.mc.StatObject(ctx, c.bucketForKey(key), key, miniogo.StatObjectOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("stat object: %w", err)
+	}
+	return info.Size, info.ETag, nil
+}
+
+// PresignedPut returns a URL a client can PUT the object's new contents to
+// directly, valid for the given expiry. The per-operation timeout does not
+// apply: signing is local and the URL outlives the call.
+func (c *Client) PresignedPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := c.mc.PresignedPutObject(ctx, c.bucketForKey(key), key, expiry)
+	if err != nil {
+		return "", fmt.Errorf("presign put object: %w", err)
+	}
+	return u.String(), nil
+}
+
+// cancelReadCloser releases the per-operation context when the download
+// reader is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the underlying reader and cancels the operation context.
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
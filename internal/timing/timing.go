@@ -0,0 +1,247 @@
+// Package timing provides opt-in decorators that log the duration of every
+// database query and object-store operation at Debug level, pinpointing
+// whether Postgres or MinIO is the bottleneck behind a slow request.
+package timing
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dtroode/gophkeeper-server/internal/logger"
+	"github.com/dtroode/gophkeeper-server/internal/model"
+)
+
+// RecordStore wraps a model.RecordStore, logging each call's duration.
+type RecordStore struct {
+	next   model.RecordStore
+	logger *logger.Logger
+}
+
+// NewRecordStore decorates the given store.
+func NewRecordStore(next model.RecordStore, l *logger.Logger) *RecordStore {
+	return &RecordStore{next: next, logger: l}
+}
+
+// observe logs one completed operation.
+func (s *RecordStore) observe(op string, start time.Time) {
+	s.logger.Debug("db query", "op", op, "duration", time.Since(start))
+}
+
+// Create passes through to the wrapped store.
+func (s *RecordStore) Create(ctx context.Context, record *model.Record) (*model.Record, bool, error) {
+	defer s.observe("record.Create", time.Now())
+	return s.next.Create(ctx, record)
+}
+
+// GetByID passes through to the wrapped store.
+func (s *RecordStore) GetByID(ctx context.Context, id uuid.UUID) (*model.Record, error) {
+	defer s.observe("record.GetByID", time.Now())
+	return s.next.GetByID(ctx, id)
+}
+
+// GetByIDIncludingDeleted passes through to the wrapped store.
+func (s *RecordStore) GetByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.Record, error) {
+	defer s.observe("record.GetByIDIncludingDeleted", time.Now())
+	return s.next.GetByIDIncludingDeleted(ctx, id)
+}
+
+// GetByIDs passes through to the wrapped store.
+func (s *RecordStore) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Record, error) {
+	defer s.observe("record.GetByIDs", time.Now())
+	return s.next.GetByIDs(ctx, ids)
+}
+
+// GetByUserID passes through to the wrapped store.
+func (s *RecordStore) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Record, error) {
+	defer s.observe("record.GetByUserID", time.Now())
+	return s.next.GetByUserID(ctx, userID)
+}
+
+// GetIDsByUserID passes through to the wrapped store.
+func (s *RecordStore) GetIDsByUserID(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	defer s.observe("record.GetIDsByUserID", time.Now())
+	return s.next.GetIDsByUserID(ctx, userID)
+}
+
+// CountByUserID passes through to the wrapped store.
+func (s *RecordStore) CountByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	defer s.observe("record.CountByUserID", time.Now())
+	return s.next.CountByUserID(ctx, userID)
+}
+
+// UsageByUserID passes through to the wrapped store.
+func (s *RecordStore) UsageByUserID(ctx context.Context, userID uuid.UUID) (*model.StorageUsage, error) {
+	defer s.observe("record.UsageByUserID", time.Now())
+	return s.next.UsageByUserID(ctx, userID)
+}
+
+// GetByUserIDAndTag passes through to the wrapped store.
+func (s *RecordStore) GetByUserIDAndTag(ctx context.Context, userID uuid.UUID, tag string) ([]*model.Record, error) {
+	defer s.observe("record.GetByUserIDAndTag", time.Now())
+	return s.next.GetByUserIDAndTag(ctx, userID, tag)
+}
+
+// GetPinnedByUserID passes through to the wrapped store.
+func (s *RecordStore) GetPinnedByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Record, error) {
+	defer s.observe("record.GetPinnedByUserID", time.Now())
+	return s.next.GetPinnedByUserID(ctx, userID)
+}
+
+// SetPinned passes through to the wrapped store.
+func (s *RecordStore) SetPinned(ctx context.Context, id uuid.UUID, pinned bool, at time.Time) error {
+	defer s.observe("record.SetPinned", time.Now())
+	return s.next.SetPinned(ctx, id, pinned, at)
+}
+
+// SetType passes through to the wrapped store.
+func (s *RecordStore) SetType(ctx context.Context, id uuid.UUID, t model.RecordType, at time.Time) error {
+	defer s.observe("record.SetType", time.Now())
+	return s.next.SetType(ctx, id, t, at)
+}
+
+// SetTags passes through to the wrapped store.
+func (s *RecordStore) SetTags(ctx context.Context, id uuid.UUID, tags []string, at time.Time) error {
+	defer s.observe("record.SetTags", time.Now())
+	return s.next.SetTags(ctx, id, tags, at)
+}
+
+// GetUpdatedAfter passes through to the wrapped store.
+func (s *RecordStore) GetUpdatedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]*model.Record, error) {
+	defer s.observe("record.GetUpdatedAfter", time.Now())
+	return s.next.GetUpdatedAfter(ctx, userID, after)
+}
+
+// GetDeletedAfter passes through to the wrapped store.
+func (s *RecordStore) GetDeletedAfter(ctx context.Context, userID uuid.UUID, after time.Time) ([]model.Tombstone, error) {
+	defer s.observe("record.GetDeletedAfter", time.Now())
+	return s.next.GetDeletedAfter(ctx, userID, after)
+}
+
+// Rekey passes through to the wrapped store.
+func (s *RecordStore) Rekey(ctx context.Context, id uuid.UUID, encryptedKey []byte, alg string, at time.Time) error {
+	defer s.observe("record.Rekey", time.Now())
+	return s.next.Rekey(ctx, id, encryptedKey, alg, at)
+}
+
+// UpdateObjectMeta passes through to the wrapped store.
+func (s *RecordStore) UpdateObjectMeta(ctx context.Context, id uuid.UUID, fileSize int64, etag string, at time.Time) error {
+	defer s.observe("record.UpdateObjectMeta", time.Now())
+	return s.next.UpdateObjectMeta(ctx, id, fileSize, etag, at)
+}
+
+// ReplaceContent passes through to the wrapped store.
+func (s *RecordStore) ReplaceContent(ctx context.Context, record *model.Record, at time.Time) error {
+	defer s.observe("record.ReplaceContent", time.Now())
+	return s.next.ReplaceContent(ctx, record, at)
+}
+
+// Delete passes through to the wrapped store.
+func (s *RecordStore) Delete(ctx context.Context, id uuid.UUID, deletedAt time.Time) error {
+	defer s.observe("record.Delete", time.Now())
+	return s.next.Delete(ctx, id, deletedAt)
+}
+
+// DeleteMany passes through to the wrapped store.
+func (s *RecordStore) DeleteMany(ctx context.Context, ids []uuid.UUID, deletedAt time.Time) error {
+	defer s.observe("record.DeleteMany", time.Now())
+	return s.next.DeleteMany(ctx, ids, deletedAt)
+}
+
+// TransferOwner passes through to the wrapped store.
+func (s *RecordStore) TransferOwner(ctx context.Context, id uuid.UUID, newOwnerID uuid.UUID, at time.Time) error {
+	defer s.observe("record.TransferOwner", time.Now())
+	return s.next.TransferOwner(ctx, id, newOwnerID, at)
+}
+
+// ListDeletedBefore passes through to the wrapped store.
+func (s *RecordStore) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]*model.Record, error) {
+	defer s.observe("record.ListDeletedBefore", time.Now())
+	return s.next.ListDeletedBefore(ctx, before, limit)
+}
+
+// ListDeletedBeforeForUser passes through to the wrapped store.
+func (s *RecordStore) ListDeletedBeforeForUser(ctx context.Context, userID uuid.UUID, before time.Time, limit int) ([]*model.Record, error) {
+	defer s.observe("record.ListDeletedBeforeForUser", time.Now())
+	return s.next.ListDeletedBeforeForUser(ctx, userID, before, limit)
+}
+
+// HardDelete passes through to the wrapped store.
+func (s *RecordStore) HardDelete(ctx context.Context, id uuid.UUID) error {
+	defer s.observe("record.HardDelete", time.Now())
+	return s.next.HardDelete(ctx, id)
+}
+
+// Storage wraps a model.Storage, logging each call's duration. Download
+// measures time to first byte: the object read happens after the call
+// returns.
+type Storage struct {
+	next   model.Storage
+	logger *logger.Logger
+}
+
+// NewStorage decorates the given storage.
+func NewStorage(next model.Storage, l *logger.Logger) *Storage {
+	return &Storage{next: next, logger: l}
+}
+
+// observe logs one completed operation.
+func (s *Storage) observe(op string, start time.Time) {
+	s.logger.Debug("storage op", "op", op, "duration", time.Since(start))
+}
+
+// Upload passes through to the wrapped storage.
+func (s *Storage) Upload(ctx context.Context, key string, data io.Reader, size int64) (string, error) {
+	defer s.observe("storage.Upload", time.Now())
+	return s.next.Upload(ctx, key, data, size)
+}
+
+// Download passes through to the wrapped storage.
+func (s *Storage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	defer s.observe("storage.Download", time.Now())
+	return s.next.Download(ctx, key)
+}
+
+// Delete passes through to the wrapped storage.
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	defer s.observe("storage.Delete", time.Now())
+	return s.next.Delete(ctx, key)
+}
+
+// Exists passes through to the wrapped storage.
+func (s *Storage) Exists(ctx context.Context, key string) (bool, error) {
+	defer s.observe("storage.Exists", time.Now())
+	return s.next.Exists(ctx, key)
+}
+
+// Stat passes through to the wrapped storage.
+func (s *Storage) Stat(ctx context.Context, key string) (int64, error) {
+	defer s.observe("storage.Stat", time.Now())
+	return s.next.Stat(ctx, key)
+}
+
+// StatETag passes through to the wrapped storage.
+func (s *Storage) StatETag(ctx context.Context, key string) (int64, string, error) {
+	defer s.observe("storage.StatETag", time.Now())
+	return s.next.StatETag(ctx, key)
+}
+
+// PresignedPut passes through to the wrapped storage.
+func (s *Storage) PresignedPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	defer s.observe("storage.PresignedPut", time.Now())
+	return s.next.PresignedPut(ctx, key, expiry)
+}
+
+// List passes through to the wrapped storage.
+func (s *Storage) List(ctx context.Context, afterKey string, limit int) ([]string, error) {
+	defer s.observe("storage.List", time.Now())
+	return s.next.List(ctx, afterKey, limit)
+}
+
+// Copy passes through to the wrapped storage.
+func (s *Storage) Copy(ctx context.Context, key string) error {
+	defer s.observe("storage.Copy", time.Now())
+	return s.next.Copy(ctx, key)
+}